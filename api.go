@@ -2,14 +2,17 @@ package oso
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/hashicorp/go-retryablehttp"
 )
 
 type apiResult struct {
@@ -82,6 +85,18 @@ type authorizeQuery struct {
 	ContextFacts []fact `json:"context_facts"`
 }
 
+type authorizeResourcesResult struct {
+	Results []concreteValue `json:"results"`
+}
+
+type authorizeResourcesQuery struct {
+	ActorType    string          `json:"actor_type"`
+	ActorId      string          `json:"actor_id"`
+	Action       string          `json:"action"`
+	Resources    []concreteValue `json:"resources"`
+	ContextFacts []fact          `json:"context_facts"`
+}
+
 type listResult struct {
 	Results []string `json:"results"`
 }
@@ -206,6 +221,8 @@ type RequestData struct {
 	path   string
 	data   interface{}
 	query  map[string]string
+	// The response body is streamed to the caller, see [do].
+	stream bool
 }
 
 func (r RequestData) Body() (io.Reader, error) {
@@ -218,7 +235,7 @@ func (r RequestData) Body() (io.Reader, error) {
 		return nil, e
 	}
 	if len(reqBodyJSON) > maxBodySize {
-		return nil, fmt.Errorf("request payload too large (body size bytes: %d, max body size: %d)", len(reqBodyJSON), maxBodySize)
+		return nil, fmt.Errorf("%w (body size bytes: %d, max body size: %d)", ErrPayloadTooLarge, len(reqBodyJSON), maxBodySize)
 	}
 	reqBodyBytes = bytes.NewBuffer(reqBodyJSON)
 
@@ -239,30 +256,50 @@ func (r RequestData) Path() string {
 
 const maxBodySize = 10 * 1024 * 1024
 
-func (c *OsoClientImpl) buildRequest(baseUrl string, requestData RequestData) (*http.Request, error) {
+func (c *OsoClientImpl) buildRequest(ctx context.Context, baseUrl string, requestData RequestData) (*http.Request, error) {
 	url := baseUrl + "/api" + requestData.path
 	body, err := requestData.Body()
 	if err != nil {
+		if errors.Is(err, ErrPayloadTooLarge) {
+			c.logger.log(levelWarn, "oso: request payload too large", "endpoint", requestData.path, "error", err)
+		}
 		return nil, err
 	}
 
-	req, err := http.NewRequest(requestData.Method(), url, body)
+	req, err := http.NewRequestWithContext(ctx, requestData.Method(), url, body)
 	if err != nil {
 		return nil, err
 	}
+	if buffer, ok := body.(*bytes.Buffer); ok {
+		if info := callInfoFromContext(ctx); info != nil {
+			info.requestBytes = buffer.Len()
+		}
+	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	apiKey, err := c.credentials.APIKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.logger.addSecret(apiKey)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("User-Agent", c.userAgent)
 	req.Header.Set("X-OsoApiVersion", "0")
 	req.Header.Set("X-Request-ID", uuid.New().String())
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("X-Oso-Instance-Id", c.clientId)
 
-	if c.lastOffset != "" {
-		req.Header.Set("OsoOffset", c.lastOffset)
+	if token, ok := consistencyTokenFromContext(ctx); ok {
+		req.Header.Set("OsoOffset", string(token))
+	} else if offset := c.offset.get(); offset != "" {
+		req.Header.Set("OsoOffset", string(offset))
 	}
 
+	if key := idempotencyKeyFromContext(ctx); key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	c.tracer.inject(ctx, req)
+
 	q := req.URL.Query()
 	queryArgs := requestData.Query()
 	for k, v := range queryArgs {
@@ -273,12 +310,65 @@ func (c *OsoClientImpl) buildRequest(baseUrl string, requestData RequestData) (*
 	return req, nil
 }
 
-func (c *OsoClientImpl) apiCall(requestData RequestData) (*http.Request, error) {
-	return c.buildRequest(c.url, requestData)
+func (c *OsoClientImpl) apiCall(ctx context.Context, requestData RequestData) (*http.Request, error) {
+	return c.buildRequest(ctx, c.url, requestData)
+}
+
+func (c *OsoClientImpl) logFinishedCall(ctx context.Context, requestData RequestData, res *http.Response, err error) {
+	if c.logger == nil {
+		return
+	}
+	info := callInfoFromContext(ctx)
+	keysAndValues := []interface{}{
+		"endpoint", requestData.path,
+		"method", requestData.method,
+		"duration", time.Since(info.start),
+		"retries", info.retries,
+		"fallback", info.fallback,
+	}
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			keysAndValues = append(keysAndValues, "status", apiErr.StatusCode, "request_id", apiErr.RequestID)
+		}
+		c.logger.log(levelWarn, "oso: request failed", append(keysAndValues, "error", err)...)
+		return
+	}
+	keysAndValues = append(keysAndValues, "status", res.StatusCode, "request_id", res.Header.Get("X-Request-ID"))
+	c.logger.log(levelDebug, "oso: request finished", keysAndValues...)
 }
 
-func (c *OsoClientImpl) fallbackApiCall(requestData RequestData) (*http.Request, error) {
-	return c.buildRequest(c.fallbackUrl, requestData)
+// Sends the request to each fallback node in turn until one succeeds. If none
+// does, returns the last response so that its error can be reported.
+func (c *OsoClientImpl) sendFallback(ctx context.Context, requestData RequestData) (*http.Response, error) {
+	var res *http.Response
+	var err error
+	for _, node := range c.fallbacks.order() {
+		if res != nil {
+			res.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		req, e := c.buildRequest(ctx, node.url, requestData)
+		if e != nil {
+			return nil, e
+		}
+		c.logger.log(levelWarn, "oso: sending request to fallback node", "endpoint", requestData.path, "fallback_url", node.url)
+		start := time.Now()
+		res, err = do(c.fallbackHttpClient, req, requestData.stream)
+		if err == nil && res.StatusCode < 500 {
+			c.fallbacks.recordSuccess(node, time.Since(start))
+			return res, nil
+		}
+		c.fallbacks.recordFailure(node)
+		if err != nil {
+			c.logger.log(levelWarn, "oso: fallback node failed", "endpoint", requestData.path, "fallback_url", node.url, "error", err)
+		} else {
+			c.logger.log(levelWarn, "oso: fallback node failed", "endpoint", requestData.path, "fallback_url", node.url, "status", res.StatusCode)
+		}
+	}
+	return res, err
 }
 
 func (c *OsoClientImpl) fallbackEligible(path string, method string) bool {
@@ -311,89 +401,238 @@ func (c *OsoClientImpl) fallbackEligible(path string, method string) bool {
 		{"/api/facts", "get"},
 		{"/api/policy_metadata", "get"},
 	}
-	return c.fallbackHttpClient != nil && contains(eligiblePaths,
+	return c.fallbacks != nil && contains(eligiblePaths,
 		endpoint{path, method},
 	)
 }
 
-func (c *OsoClientImpl) doRequest(requestData RequestData, output interface{}, isMutation bool) error {
-	return c.doRequestWithParityHandle(requestData, output, isMutation, nil)
+func (c *OsoClientImpl) doRequest(ctx context.Context, requestData RequestData, output interface{}, isMutation bool) error {
+	return c.doRequestWithParityHandle(ctx, requestData, output, isMutation, nil)
 }
 
 // Actually send the request. Takes request data that can be used to build the
 // relevant request with different base urls. This is needed to handle falling
 // back to a host at a different base url.
-func (c *OsoClientImpl) doRequestWithParityHandle(requestData RequestData, output interface{}, isMutation bool, parityHandle *ParityHandle) error {
-	req, err := c.apiCall(requestData)
+//
+// The context is attached to every request that is sent, so cancelling it
+// aborts the primary request, any pending retries, and the fallback request.
+func (c *OsoClientImpl) doRequestWithParityHandle(ctx context.Context, requestData RequestData, output interface{}, isMutation bool, parityHandle *ParityHandle) error {
+	res, err := c.send(ctx, requestData, isMutation, parityHandle)
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
+	resBodyJSON, e := io.ReadAll(res.Body)
+	if e != nil {
+		return e
+	}
+	return json.Unmarshal(resBodyJSON, output)
+}
+
+// Details of a single API call, filled in as it is sent. A call may be made of
+// several HTTP requests: retries, and a final request to the fallback URL.
+type callInfo struct {
+	start         time.Time
+	endpoint      string
+	statusCode    int
+	retries       int
+	fallback      bool
+	requestBytes  int
+	responseBytes int
+}
+
+type callInfoKey struct{}
+
+func callInfoFromContext(ctx context.Context) *callInfo {
+	info, _ := ctx.Value(callInfoKey{}).(*callInfo)
+	return info
+}
+
+// Returns a hook which counts and logs retries of the call the request belongs
+// to. Installed as the RequestLogHook of the retrying HTTP client.
+func logRetries(logger *clientLogger) retryablehttp.RequestLogHook {
+	return func(_ retryablehttp.Logger, req *http.Request, attempt int) {
+		info := callInfoFromContext(req.Context())
+		if info == nil {
+			return
+		}
+		info.retries = attempt
+		if attempt > 0 {
+			logger.log(levelInfo, "oso: retrying request", "endpoint", info.endpoint, "method", req.Method, "attempt", attempt)
+		}
+	}
+}
+
+// Send the request, falling back if needed, and return the successful
+// response with its body unread. The caller must close the body. Non-2xx
+// responses are returned as an [*APIError].
+func (c *OsoClientImpl) send(ctx context.Context, requestData RequestData, isMutation bool, parityHandle *ParityHandle) (*http.Response, error) {
+	ctx = context.WithValue(ctx, callInfoKey{}, &callInfo{start: time.Now(), endpoint: requestData.path})
+	c.retrier.startCall()
+	ctx = c.tracer.startCall(ctx, requestData)
+	c.logger.log(levelDebug, "oso: request started", "endpoint", requestData.path, "method", requestData.method)
+	res, err := c.sendCall(ctx, requestData, isMutation, parityHandle)
+	c.tracer.finishCall(ctx, res, err)
+	c.logFinishedCall(ctx, requestData, res, err)
+	if c.metrics != nil {
+		if err != nil {
+			c.observeRequest(ctx, requestData, err)
+		} else {
+			callInfoFromContext(ctx).statusCode = res.StatusCode
+			// Report once the body has been read, so that the duration and
+			// size cover the whole response.
+			res.Body = &observedBody{ReadCloser: res.Body, observe: func(n int) {
+				callInfoFromContext(ctx).responseBytes = n
+				c.observeRequest(ctx, requestData, nil)
+			}}
+		}
+	}
+	return res, err
+}
+
+// Sends req with client. The client's timeout normally covers reading the
+// response body too, which would cut off a long streamed response partway, so
+// for streamed requests it only applies until the response headers arrive.
+func do(client *http.Client, req *http.Request, stream bool) (*http.Response, error) {
+	if !stream || client.Timeout == 0 {
+		return client.Do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(client.Timeout, cancel)
+	untimed := *client
+	untimed.Timeout = 0
+	res, err := untimed.Do(req.WithContext(ctx))
+	if !timer.Stop() {
+		if err == nil {
+			res.Body.Close()
+		}
+		cancel()
+		return nil, fmt.Errorf("%s %s: timed out after %v awaiting response headers: %w", req.Method, req.URL.Path, client.Timeout, context.DeadlineExceeded)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// A response body which releases its request's context once closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (c *OsoClientImpl) sendCall(ctx context.Context, requestData RequestData, isMutation bool, parityHandle *ParityHandle) (*http.Response, error) {
+	req, err := c.apiCall(ctx, requestData)
+	if err != nil {
+		return nil, err
+	}
 
-	// make requests with retryclient
-	res, e := c.httpClient.Do(req)
+	// Calls that can be answered by the fallback skip Oso Cloud while the
+	// circuit breaker is open.
+	var res *http.Response
+	var e error
+	breaker := c.breaker
+	if breaker != nil && !c.fallbackEligible(req.URL.EscapedPath(), req.Method) {
+		breaker = nil
+	}
+	if allowed, probe := breaker.allowCall(); allowed {
+		// make requests with retryclient
+		res, e = do(c.httpClient, req, requestData.stream)
+		if e == nil && res.StatusCode == http.StatusUnauthorized {
+			res, e = c.retryUnauthorized(ctx, requestData, req, res)
+		}
+		if ctx.Err() != nil {
+			breaker.release(probe)
+		} else {
+			breaker.record(probe, e == nil && res.StatusCode != 400 && res.StatusCode < 500)
+		}
+	} else {
+		e = errCircuitOpen
+	}
+	fallbackAttempted := false
 	// NOTE: We have had cases where internal policy evaluation errors have
 	// resulted in 400s, so defensively allow 400s to retry on the fallback
 	// service.
 	if e != nil || res.StatusCode == 400 || res.StatusCode >= 500 {
 		// attempt to make a final request to fallbackURL if configured
-		if c.fallbackEligible(req.URL.EscapedPath(), req.Method) {
+		if ctx.Err() != nil {
+			// The caller gave up on this request, so there is no point in
+			// asking the fallback service either.
+			if res != nil {
+				res.Body.Close()
+			}
+			return nil, ctx.Err()
+		} else if c.fallbackEligible(req.URL.EscapedPath(), req.Method) {
 			// Build a new request object for the fallback request
 			// NOTE: We can't reuse the original request object because the data in
 			// the body is already consumed at this point.
-			req, e := c.fallbackApiCall(requestData)
-			if e != nil {
-				return e
+			if res != nil {
+				res.Body.Close()
 			}
-			res, e = c.fallbackHttpClient.Do(req)
+			fallbackAttempted = true
+			callInfoFromContext(ctx).fallback = true
+			res, e = c.sendFallback(ctx, requestData)
 			if e != nil {
-				return e
+				return nil, e
 			}
 		} else {
 			// If status code is not 2xx and we don't have fallback configured, we
 			// can get into this branch without an error set. In that case we want
 			// to continue and return the response object to the caller.
 			if e != nil {
-				return e
+				return nil, e
 			}
 		}
 	}
-	defer res.Body.Close()
-	resBodyJSON, e := io.ReadAll(res.Body)
-	if e != nil {
-		return e
-	}
 	// Re: ENG-984, non-2xx response codes are treated as errors
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		var apiErr apiError
-		e = json.Unmarshal(resBodyJSON, &apiErr)
+		defer res.Body.Close()
+		resBodyJSON, e := io.ReadAll(res.Body)
 		if e != nil {
-			return e
+			return nil, e
+		}
+		callInfoFromContext(ctx).responseBytes = len(resBodyJSON)
+		var apiErr apiError
+		if e = json.Unmarshal(resBodyJSON, &apiErr); e != nil {
+			// Proxies and load balancers don't necessarily respond with JSON.
+			apiErr.Message = strings.TrimSpace(string(resBodyJSON))
+		}
+		return nil, &APIError{
+			StatusCode:        res.StatusCode,
+			Message:           apiErr.Message,
+			RequestID:         res.Header.Get("X-Request-ID"),
+			Endpoint:          requestData.path,
+			FallbackAttempted: fallbackAttempted,
 		}
-		requestID := res.Header.Get("X-Request-ID")
-		return errors.New("Oso Cloud error: " + apiErr.Message + " (Request ID: " + requestID + ")")
 	}
 
 	if parityHandle != nil {
 		requestID := res.Header.Get("X-Request-ID")
 		if requestID == "" {
-			return errors.New("unable to use Parity Handle: no request ID returned from Oso")
+			res.Body.Close()
+			return nil, errors.New("unable to use Parity Handle: no request ID returned from Oso")
 		}
 		if err := parityHandle.set(requestID, c); err != nil {
-			return err
+			res.Body.Close()
+			return nil, err
 		}
 	}
 
 	if isMutation {
-		c.lastOffset = res.Header.Get("OsoOffset")
+		c.observeOffset(ctx, ConsistencyToken(res.Header.Get("OsoOffset")))
 	}
-	e = json.Unmarshal(resBodyJSON, output)
-	if e != nil {
-		return e
-	}
-	return nil
+	return res, nil
 }
 
-func (c *OsoClientImpl) get(path string, query map[string]string, output interface{}) error {
+func (c *OsoClientImpl) get(ctx context.Context, path string, query map[string]string, output interface{}) error {
 	requestData := RequestData{
 		method: "GET",
 		path:   path,
@@ -401,97 +640,110 @@ func (c *OsoClientImpl) get(path string, query map[string]string, output interfa
 		query:  query,
 	}
 
-	return c.doRequest(requestData, output, false)
+	return c.doRequest(ctx, requestData, output, false)
 }
 
-func (c *OsoClientImpl) post(path string, data interface{}, output interface{}, isMutation bool) error {
-	return c.postWithParityHandle(path, data, output, isMutation, nil)
+func (c *OsoClientImpl) post(ctx context.Context, path string, data interface{}, output interface{}, isMutation bool) error {
+	return c.postWithParityHandle(ctx, path, data, output, isMutation, nil)
 }
 
-func (c *OsoClientImpl) postWithParityHandle(path string, data interface{}, output interface{}, isMutation bool, parityHandle *ParityHandle) error {
+func (c *OsoClientImpl) postWithParityHandle(ctx context.Context, path string, data interface{}, output interface{}, isMutation bool, parityHandle *ParityHandle) error {
 	requestData := RequestData{
 		method: "POST",
 		path:   path,
 		data:   data,
 		query:  nil,
 	}
-	return c.doRequestWithParityHandle(requestData, output, isMutation, parityHandle)
+	return c.doRequestWithParityHandle(ctx, requestData, output, isMutation, parityHandle)
 }
 
-func (c *OsoClientImpl) delete(path string, data interface{}, output interface{}) error {
+func (c *OsoClientImpl) delete(ctx context.Context, path string, data interface{}, output interface{}) error {
 	requestData := RequestData{
 		method: "DELETE",
 		path:   path,
 		data:   data,
 		query:  nil,
 	}
-	return c.doRequest(requestData, output, true)
+	return c.doRequest(ctx, requestData, output, true)
 }
 
-func (c *OsoClientImpl) getPolicy() (*getPolicyResult, error) {
+func (c *OsoClientImpl) getPolicy(ctx context.Context) (*getPolicyResult, error) {
 	var result getPolicyResult
-	if e := c.get("/policy", nil, &result); e != nil {
+	if e := c.get(ctx, "/policy", nil, &result); e != nil {
 		return nil, e
 	}
 	return &result, nil
 }
 
-func (c *OsoClientImpl) getPolicyMetadataResult(version *string) (*getPolicyMetadataResult, error) {
+func (c *OsoClientImpl) getPolicyMetadataResult(ctx context.Context, version *string) (*getPolicyMetadataResult, error) {
 	var result getPolicyMetadataResult
 	params := make(map[string]string)
 	if version != nil {
 		params["version"] = *version
 	}
-	if e := c.get("/policy_metadata", params, &result); e != nil {
+	if e := c.get(ctx, "/policy_metadata", params, &result); e != nil {
 		return nil, e
 	}
 	return &result, nil
 }
 
-func (c *OsoClientImpl) postPolicy(data policy) (*apiResult, error) {
+func (c *OsoClientImpl) postPolicy(ctx context.Context, data policy) (*apiResult, error) {
 	var resBody apiResult
-	if e := c.post("/policy", data, &resBody, true); e != nil {
+	if e := c.post(ctx, "/policy", data, &resBody, true); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) postFacts(data fact) (*apiResult, error) {
+func (c *OsoClientImpl) postFacts(ctx context.Context, data fact) (*apiResult, error) {
 	url := "/batch"
 	changesets := []factChangeset{batchInserts{Inserts: []fact{data}}}
 
 	var resBody apiResult
-	if e := c.post(url, changesets, &resBody, true); e != nil {
+	if e := c.post(ctx, url, changesets, &resBody, true); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) deleteFacts(data factPattern) (*apiResult, error) {
+func (c *OsoClientImpl) deleteFacts(ctx context.Context, data factPattern) (*apiResult, error) {
 	url := "/batch"
 
 	changesets := []factChangeset{batchDeletes{Deletes: []factPattern{data}}}
 	var resBody apiResult
-	if e := c.post(url, changesets, &resBody, true); e != nil {
+	if e := c.post(ctx, url, changesets, &resBody, true); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) postBatch(data []factChangeset) (*apiResult, error) {
+func (c *OsoClientImpl) postBatch(ctx context.Context, data []factChangeset) (*apiResult, error) {
 	url := "/batch"
 	var resBody apiResult
-	if e := c.post(url, data, &resBody, true); e != nil {
+	if e := c.post(ctx, url, data, &resBody, true); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) postAuthorize(data authorizeQuery, parityHandle *ParityHandle) (*authorizeResult, error) {
+func (c *OsoClientImpl) postAuthorize(ctx context.Context, data authorizeQuery, parityHandle *ParityHandle) (*authorizeResult, error) {
 	url := "/authorize"
+	if parityHandle == nil {
+		allowed, e := c.cachedDecision(ctx, url, data, func() (interface{}, error) {
+			var resBody authorizeResult
+			if e := c.post(ctx, url, data, &resBody, false); e != nil {
+				return nil, e
+			}
+			return resBody.Allowed, nil
+		})
+		if e != nil {
+			return nil, e
+		}
+		return &authorizeResult{Allowed: allowed.(bool)}, nil
+	}
 	var resBody authorizeResult
 
-	e := c.postWithParityHandle(url, data, &resBody, false, parityHandle)
+	e := c.postWithParityHandle(ctx, url, data, &resBody, false, parityHandle)
 
 	if e != nil {
 		return nil, e
@@ -499,53 +751,68 @@ func (c *OsoClientImpl) postAuthorize(data authorizeQuery, parityHandle *ParityH
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) postList(data listQuery) (*listResult, error) {
+func (c *OsoClientImpl) postAuthorizeResources(ctx context.Context, data authorizeResourcesQuery) (*authorizeResourcesResult, error) {
+	url := "/authorize_resources"
+	var resBody authorizeResourcesResult
+	if e := c.post(ctx, url, data, &resBody, false); e != nil {
+		return nil, e
+	}
+	return &resBody, nil
+}
+
+func (c *OsoClientImpl) postList(ctx context.Context, data listQuery) (*listResult, error) {
 	url := "/list"
 	var resBody listResult
-	if e := c.post(url, data, &resBody, false); e != nil {
+	if e := c.post(ctx, url, data, &resBody, false); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) postActions(data actionsQuery) (*actionsResult, error) {
+func (c *OsoClientImpl) postActions(ctx context.Context, data actionsQuery) (*actionsResult, error) {
 	url := "/actions"
-	var resBody actionsResult
-	if e := c.post(url, data, &resBody, false); e != nil {
+	actions, e := c.cachedDecision(ctx, url, data, func() (interface{}, error) {
+		var resBody actionsResult
+		if e := c.post(ctx, url, data, &resBody, false); e != nil {
+			return nil, e
+		}
+		return resBody.Results, nil
+	})
+	if e != nil {
 		return nil, e
 	}
-	return &resBody, nil
+	// Copy so that callers can't modify the cached slice.
+	return &actionsResult{Results: append([]string(nil), actions.([]string)...)}, nil
 }
 
-func (c *OsoClientImpl) postQuery(data query) (*queryResult, error) {
+func (c *OsoClientImpl) postQuery(ctx context.Context, data query) (*queryResult, error) {
 	url := "/evaluate_query"
 	var resBody queryResult
-	if e := c.post(url, data, &resBody, false); e != nil {
+	if e := c.post(ctx, url, data, &resBody, false); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) getStats() (*statsResult, error) {
+func (c *OsoClientImpl) getStats(ctx context.Context) (*statsResult, error) {
 	url := "/stats"
 	var resBody statsResult
-	if e := c.get(url, nil, &resBody); e != nil {
+	if e := c.get(ctx, url, nil, &resBody); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) clearData() (*apiResult, error) {
+func (c *OsoClientImpl) clearData(ctx context.Context) (*apiResult, error) {
 	url := "/clear_data"
 	var resBody apiResult
-	if e := c.post(url, nil, &resBody, true); e != nil {
+	if e := c.post(ctx, url, nil, &resBody, true); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) getFacts(data factPattern) ([]fact, error) {
-	url := "/facts"
+func factsParams(data factPattern) map[string]string {
 	params := make(map[string]string)
 	params["predicate"] = data.Predicate
 	for i, arg := range data.Args {
@@ -556,14 +823,36 @@ func (c *OsoClientImpl) getFacts(data factPattern) ([]fact, error) {
 			params[fmt.Sprintf("args.%d.id", i)] = *arg.Id
 		}
 	}
+	return params
+}
+
+func (c *OsoClientImpl) getFacts(ctx context.Context, data factPattern) ([]fact, error) {
+	url := "/facts"
 	var resBody []fact
-	if e := c.get(url, params, &resBody); e != nil {
+	if e := c.get(ctx, url, factsParams(data), &resBody); e != nil {
 		return nil, e
 	}
 	return resBody, nil
 }
 
-func (c *OsoClientImpl) postAuthorizeQuery(query authorizeQuery, parityHandle *ParityHandle) (*localQueryResult, error) {
+// Like getFacts, but returns the response body unread so that it can be
+// decoded incrementally. The caller must close it.
+func (c *OsoClientImpl) getFactsStream(ctx context.Context, data factPattern) (io.ReadCloser, error) {
+	requestData := RequestData{
+		method: "GET",
+		path:   "/facts",
+		data:   nil,
+		query:  factsParams(data),
+		stream: true,
+	}
+	res, e := c.send(ctx, requestData, false, nil)
+	if e != nil {
+		return nil, e
+	}
+	return res.Body, nil
+}
+
+func (c *OsoClientImpl) postAuthorizeQuery(ctx context.Context, query authorizeQuery, parityHandle *ParityHandle) (*localQueryResult, error) {
 	url := "/authorize_query"
 	data := localAuthQuery{
 		Query:        query,
@@ -571,7 +860,7 @@ func (c *OsoClientImpl) postAuthorizeQuery(query authorizeQuery, parityHandle *P
 	}
 	var resBody localQueryResult
 
-	e := c.postWithParityHandle(url, data, &resBody, false, parityHandle)
+	e := c.postWithParityHandle(ctx, url, data, &resBody, false, parityHandle)
 
 	if e != nil {
 		return nil, e
@@ -579,7 +868,7 @@ func (c *OsoClientImpl) postAuthorizeQuery(query authorizeQuery, parityHandle *P
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) postListQuery(query listQuery, column string) (*localQueryResult, error) {
+func (c *OsoClientImpl) postListQuery(ctx context.Context, query listQuery, column string) (*localQueryResult, error) {
 	url := "/list_query"
 	data := localListQuery{
 		Query:        query,
@@ -587,26 +876,26 @@ func (c *OsoClientImpl) postListQuery(query listQuery, column string) (*localQue
 		DataBindings: c.dataBindings,
 	}
 	var resBody localQueryResult
-	if e := c.post(url, data, &resBody, false); e != nil {
+	if e := c.post(ctx, url, data, &resBody, false); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) postActionsQuery(query actionsQuery) (*localQueryResult, error) {
+func (c *OsoClientImpl) postActionsQuery(ctx context.Context, query actionsQuery) (*localQueryResult, error) {
 	url := "/actions_query"
 	data := localActionsQuery{
 		Query:        query,
 		DataBindings: c.dataBindings,
 	}
 	var resBody localQueryResult
-	if e := c.post(url, data, &resBody, false); e != nil {
+	if e := c.post(ctx, url, data, &resBody, false); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) postQueryLocal(query query, mode interface{}) (*localQueryResult, error) {
+func (c *OsoClientImpl) postQueryLocal(ctx context.Context, query query, mode interface{}) (*localQueryResult, error) {
 	url := "/evaluate_query_local"
 	data := localQuery{
 		Query:        query,
@@ -614,15 +903,15 @@ func (c *OsoClientImpl) postQueryLocal(query query, mode interface{}) (*localQue
 		Mode:         mode,
 	}
 	var resBody localQueryResult
-	if e := c.post(url, data, &resBody, false); e != nil {
+	if e := c.post(ctx, url, data, &resBody, false); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
 }
 
-func (c *OsoClientImpl) postExpectedResult(expectedResult expectedResult) (*apiResult, error) {
+func (c *OsoClientImpl) postExpectedResult(ctx context.Context, expectedResult expectedResult) (*apiResult, error) {
 	var resBody apiResult
-	if e := c.post("/expect", expectedResult, &resBody, false); e != nil {
+	if e := c.post(ctx, "/expect", expectedResult, &resBody, false); e != nil {
 		return nil, e
 	}
 	return &resBody, nil
@@ -0,0 +1,17 @@
+package oso
+
+import "github.com/osohq/go-oso-cloud/v2/internal/backend"
+
+// Lets the osotest fake create query builders, batch transactions and
+// iterators, without exporting their constructors.
+func init() {
+	backend.NewQueryBuilder = func(fact interface{}, evaluate backend.QueryFunc) interface{} {
+		return newBuilder(queryFuncBackend(evaluate), fact.(QueryFact))
+	}
+	backend.RecordBatch = func(fn interface{}) ([]backend.Operation, error) {
+		return recordBatch(fn.(func(BatchTransaction)))
+	}
+	backend.NewFactIterator = func(facts interface{}) interface{} {
+		return &FactIterator{started: true, done: true, buffered: facts.([]Fact)}
+	}
+}
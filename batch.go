@@ -0,0 +1,112 @@
+package oso
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The outcome of [OsoClientImpl.BatchChunked].
+type BatchResult struct {
+	// The number of chunks that were committed. Chunks are committed in order,
+	// so these are always the first ChunksCommitted chunks.
+	ChunksCommitted int
+	// The number of chunks the batch was split into.
+	ChunksTotal int
+}
+
+// JSON overhead of an empty changeset, eg. {"inserts":[]}.
+var (
+	insertsOverhead = len(`{"inserts":[]}`)
+	deletesOverhead = len(`{"deletes":[]}`)
+)
+
+// Splits changesets into chunks whose JSON encoding is at most limit bytes,
+// keeping every insert and delete in its original order. A changeset is split
+// across chunks when it doesn't fit in what remains of the current chunk.
+func chunkChangesets(changesets []factChangeset, limit int) ([][]factChangeset, error) {
+	chunks := [][]factChangeset{}
+	var chunk []factChangeset
+	// The encoded size of chunk, including the surrounding brackets.
+	size := 2
+
+	flush := func() {
+		if len(chunk) > 0 {
+			chunks = append(chunks, chunk)
+		}
+		chunk = nil
+		size = 2
+	}
+
+	// Adds an encoded item of the given kind, starting a new changeset (and
+	// possibly a new chunk) when needed.
+	add := func(isInsert bool, item interface{}, itemSize int) error {
+		overhead := deletesOverhead
+		if isInsert {
+			overhead = insertsOverhead
+		}
+		if 2+overhead+itemSize > limit {
+			return fmt.Errorf("%w (a single fact is %d bytes, max body size: %d)", ErrPayloadTooLarge, itemSize, limit)
+		}
+
+		last := len(chunk) - 1
+		extendsLast := last >= 0 && chunk[last].isInsert() == isInsert
+		needed := itemSize
+		if extendsLast {
+			needed++ // the comma between items
+		} else {
+			needed += overhead
+			if last >= 0 {
+				needed++ // the comma between changesets
+			}
+		}
+		if size+needed > limit {
+			flush()
+			extendsLast = false
+			needed = overhead + itemSize
+		}
+
+		size += needed
+		if extendsLast {
+			switch changeset := chunk[last].(type) {
+			case batchInserts:
+				changeset.Inserts = append(changeset.Inserts, item.(fact))
+				chunk[last] = changeset
+			case batchDeletes:
+				changeset.Deletes = append(changeset.Deletes, item.(factPattern))
+				chunk[last] = changeset
+			}
+		} else if isInsert {
+			chunk = append(chunk, batchInserts{Inserts: []fact{item.(fact)}})
+		} else {
+			chunk = append(chunk, batchDeletes{Deletes: []factPattern{item.(factPattern)}})
+		}
+		return nil
+	}
+
+	for _, changeset := range changesets {
+		switch c := changeset.(type) {
+		case batchInserts:
+			for _, f := range c.Inserts {
+				encoded, err := json.Marshal(f)
+				if err != nil {
+					return nil, err
+				}
+				if err := add(true, f, len(encoded)); err != nil {
+					return nil, err
+				}
+			}
+		case batchDeletes:
+			for _, p := range c.Deletes {
+				encoded, err := json.Marshal(p)
+				if err != nil {
+					return nil, err
+				}
+				if err := add(false, p, len(encoded)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	flush()
+	return chunks, nil
+}
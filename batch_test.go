@@ -0,0 +1,137 @@
+package oso
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// Flattens changesets into a list of operations, so that chunked and
+// unchunked batches can be compared.
+func flattenChangesets(changesets []factChangeset) []string {
+	operations := []string{}
+	for _, changeset := range changesets {
+		switch c := changeset.(type) {
+		case batchInserts:
+			for _, f := range c.Inserts {
+				encoded, _ := json.Marshal(f)
+				operations = append(operations, "insert "+string(encoded))
+			}
+		case batchDeletes:
+			for _, p := range c.Deletes {
+				encoded, _ := json.Marshal(p)
+				operations = append(operations, "delete "+string(encoded))
+			}
+		}
+	}
+	return operations
+}
+
+func TestChunkChangesets(t *testing.T) {
+	tx := batchTransaction{changesets: []factChangeset{}}
+	for i := 0; i < 50; i++ {
+		user := NewValue("User", strconv.Itoa(i))
+		tx.Insert(NewFact("has_role", user, String("member"), NewValue("Repo", "acme")))
+		if i%7 == 0 {
+			tx.Delete(NewFactPattern("has_role", user, nil, nil))
+		}
+	}
+
+	for _, limit := range []int{200, 1000, 5000, maxBodySize} {
+		chunks, err := chunkChangesets(tx.changesets, limit)
+		if err != nil {
+			t.Fatalf("chunkChangesets(%d) failed: %v", limit, err)
+		}
+		operations := []string{}
+		for _, chunk := range chunks {
+			encoded, _ := json.Marshal(chunk)
+			if len(encoded) > limit {
+				t.Fatalf("chunk of %d bytes exceeds limit %d", len(encoded), limit)
+			}
+			operations = append(operations, flattenChangesets(chunk)...)
+		}
+		if !reflect.DeepEqual(operations, flattenChangesets(tx.changesets)) {
+			t.Fatalf("chunking with limit %d changed the operations", limit)
+		}
+		if limit == maxBodySize && len(chunks) != 1 {
+			t.Fatalf("small batch split into %d chunks", len(chunks))
+		}
+	}
+
+	if _, err := chunkChangesets(tx.changesets, 50); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("chunkChangesets error = %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+func TestBatchChunked(t *testing.T) {
+	var chunks int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&chunks, 1) == 3 {
+			w.WriteHeader(500)
+			w.Write([]byte(`{"message":"boom"}`))
+			return
+		}
+		w.Write([]byte(`{"message":"ok"}`))
+	}))
+	defer server.Close()
+
+	o, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", WithRetryPolicy(RetryPolicy{MaxRetries: 0}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	id := strings.Repeat("x", 1000)
+	batch := func(tx BatchTransaction) {
+		for i := 0; i < 25000; i++ {
+			tx.Insert(NewFact("has_role", NewValue("User", strconv.Itoa(i)), String("member"), NewValue("Repo", id)))
+		}
+	}
+
+	if err = o.Batch(batch); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("Batch error = %v, want ErrPayloadTooLarge", err)
+	}
+
+	result, err := o.BatchChunked(batch)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 500 {
+		t.Fatalf("BatchChunked error = %v, want 500 APIError", err)
+	}
+	if result.ChunksCommitted != 2 || result.ChunksTotal != 3 {
+		t.Fatalf("BatchChunked = %+v, want 2 of 3 chunks committed", result)
+	}
+}
+
+func TestBatchChunkedEmpty(t *testing.T) {
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, strings.TrimSpace(string(body)))
+		w.Header().Set("OsoOffset", "7")
+		w.Write([]byte(`{"message":"ok"}`))
+	}))
+	defer server.Close()
+
+	o, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	result, err := o.BatchChunked(func(tx BatchTransaction) {})
+	if err != nil {
+		t.Fatalf("BatchChunked failed: %v", err)
+	}
+	if result.ChunksCommitted != 1 || result.ChunksTotal != 1 {
+		t.Fatalf("BatchChunked = %+v, want 1 of 1 chunks committed", result)
+	}
+	if !reflect.DeepEqual(bodies, []string{"[]"}) {
+		t.Fatalf("BatchChunked sent %q, want a single empty batch", bodies)
+	}
+	if offset := o.offset.get(); offset != "7" {
+		t.Fatalf("offset = %q, want %q", offset, "7")
+	}
+}
@@ -0,0 +1,208 @@
+package oso
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// The defaults used for zero-valued fields of [DecisionCacheConfig].
+const (
+	DefaultDecisionCacheTTL     = 5 * time.Second
+	DefaultDecisionCacheMaxSize = 10000
+)
+
+// Configures the in-process cache of authorization decisions. See [WithDecisionCache].
+type DecisionCacheConfig struct {
+	// How long a decision is reused for. Defaults to [DefaultDecisionCacheTTL].
+	TTL time.Duration
+	// The maximum number of decisions to keep. When the cache is full, the
+	// least recently used decision is evicted. Defaults to [DefaultDecisionCacheMaxSize].
+	MaxSize int
+}
+
+// Counters describing the effectiveness of the decision cache.
+type DecisionCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// The number of decisions currently cached.
+	Size int
+}
+
+// Cache the results of Authorize and Actions calls in memory.
+//
+// Decisions are keyed by actor, action, resource and context facts, and are
+// dropped after the configured TTL. The whole cache is invalidated whenever
+// this client writes to Oso Cloud (Insert, Delete, Batch or Policy) and the
+// OsoOffset returned by Oso Cloud changes. Writes made by other clients are
+// only picked up once the TTL expires, so keep it short.
+//
+// Calls that use a [ParityHandle] always bypass the cache.
+func WithDecisionCache(config DecisionCacheConfig) Option {
+	return func(o *clientOptions) {
+		o.decisionCache = &config
+	}
+}
+
+type decisionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	entries map[string]*list.Element
+	lru     *list.List // most recently used at the front
+	offset  string
+	// Incremented on every invalidation, so that decisions fetched before an
+	// invalidation aren't cached after it.
+	generation uint64
+	hits       uint64
+	misses     uint64
+	evictions  uint64
+	now        func() time.Time
+}
+
+type decisionCacheEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+func newDecisionCache(config DecisionCacheConfig) *decisionCache {
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = DefaultDecisionCacheTTL
+	}
+	maxSize := config.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultDecisionCacheMaxSize
+	}
+	return &decisionCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Builds a cache key from the kind of decision and the query payload, which
+// contains everything the decision depends on.
+func decisionCacheKey(kind string, query interface{}) (string, error) {
+	data, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	return kind + ":" + string(data), nil
+}
+
+// Returns the cached value for key, if any, along with the current generation
+// which must be passed back to set.
+func (d *decisionCache) get(key string) (interface{}, uint64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	elem, ok := d.entries[key]
+	if !ok {
+		d.misses++
+		return nil, d.generation, false
+	}
+	entry := elem.Value.(*decisionCacheEntry)
+	if !d.now().Before(entry.expires) {
+		d.remove(elem)
+		d.misses++
+		return nil, d.generation, false
+	}
+	d.lru.MoveToFront(elem)
+	d.hits++
+	return entry.value, d.generation, true
+}
+
+func (d *decisionCache) set(key string, value interface{}, generation uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if generation != d.generation {
+		// The cache was invalidated while the value was being fetched, so it
+		// may already be stale.
+		return
+	}
+	expires := d.now().Add(d.ttl)
+	if elem, ok := d.entries[key]; ok {
+		entry := elem.Value.(*decisionCacheEntry)
+		entry.value = value
+		entry.expires = expires
+		d.lru.MoveToFront(elem)
+		return
+	}
+	d.entries[key] = d.lru.PushFront(&decisionCacheEntry{key: key, value: value, expires: expires})
+	for d.lru.Len() > d.maxSize {
+		d.remove(d.lru.Back())
+		d.evictions++
+	}
+}
+
+func (d *decisionCache) remove(elem *list.Element) {
+	d.lru.Remove(elem)
+	delete(d.entries, elem.Value.(*decisionCacheEntry).key)
+}
+
+// Called after every successful mutation with the OsoOffset Oso Cloud
+// returned. If the offset moved (or is unknown), the data our decisions were
+// based on has changed, so we drop all of them.
+func (d *decisionCache) observeMutation(offset string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if offset != "" && offset == d.offset {
+		return
+	}
+	d.offset = offset
+	d.generation++
+	d.entries = make(map[string]*list.Element)
+	d.lru.Init()
+}
+
+func (d *decisionCache) stats() DecisionCacheStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return DecisionCacheStats{
+		Hits:      d.hits,
+		Misses:    d.misses,
+		Evictions: d.evictions,
+		Size:      d.lru.Len(),
+	}
+}
+
+// Returns the cached decision for the given query if there is one, and
+// otherwise calls fetch and caches its result. Errors are never cached.
+func (c *OsoClientImpl) cachedDecision(ctx context.Context, kind string, query interface{}, fetch func() (interface{}, error)) (interface{}, error) {
+	if c.cache == nil {
+		return fetch()
+	}
+	if _, ok := consistencyTokenFromContext(ctx); ok {
+		// The cache only knows about writes made by this client.
+		return fetch()
+	}
+	key, err := decisionCacheKey(kind, query)
+	if err != nil {
+		return nil, err
+	}
+	value, generation, ok := c.cache.get(key)
+	if ok {
+		return value, nil
+	}
+	value, err = fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.cache.set(key, value, generation)
+	return value, nil
+}
+
+// Returns counters describing the decision cache configured with
+// [WithDecisionCache]. Returns zero stats if the cache is disabled.
+func (c *OsoClientImpl) DecisionCacheStats() DecisionCacheStats {
+	if c.cache == nil {
+		return DecisionCacheStats{}
+	}
+	return c.cache.stats()
+}
@@ -0,0 +1,105 @@
+package oso
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDecisionCacheLRUAndTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := newDecisionCache(DecisionCacheConfig{TTL: time.Minute, MaxSize: 2})
+	cache.now = func() time.Time { return now }
+
+	_, gen, _ := cache.get("a")
+	cache.set("a", true, gen)
+	cache.set("b", false, gen)
+	if _, _, ok := cache.get("a"); !ok {
+		t.Fatalf("expected a to be cached")
+	}
+	// "b" is now the least recently used entry, so it is evicted.
+	cache.set("c", true, gen)
+	if _, _, ok := cache.get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+
+	now = now.Add(time.Minute)
+	if _, _, ok := cache.get("a"); ok {
+		t.Fatalf("expected a to expire")
+	}
+
+	expected := DecisionCacheStats{Hits: 1, Misses: 3, Evictions: 1, Size: 1}
+	if stats := cache.stats(); stats != expected {
+		t.Fatalf("stats = %+v, want %+v", stats, expected)
+	}
+
+	// Values fetched before an invalidation are not cached after it.
+	_, gen, _ = cache.get("d")
+	cache.observeMutation("1")
+	cache.set("d", true, gen)
+	if _, _, ok := cache.get("d"); ok {
+		t.Fatalf("expected stale value not to be cached")
+	}
+}
+
+func TestDecisionCacheInvalidation(t *testing.T) {
+	var authorizeCalls, actionsCalls, offset int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/authorize":
+			atomic.AddInt32(&authorizeCalls, 1)
+			w.Write([]byte(`{"allowed": true}`))
+		case "/api/actions":
+			atomic.AddInt32(&actionsCalls, 1)
+			w.Write([]byte(`{"results": ["read"]}`))
+		case "/api/batch":
+			w.Header().Set("OsoOffset", strconv.Itoa(int(atomic.AddInt32(&offset, 1))))
+			w.Write([]byte(`{"message": "ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	o, _ := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", WithDecisionCache(DecisionCacheConfig{}))
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+
+	for i := 0; i < 3; i++ {
+		if allowed, e := o.Authorize(alice, "read", acme); e != nil || !allowed {
+			t.Fatalf("Authorize = %t, %v, want %t", allowed, e, true)
+		}
+		actions, e := o.Actions(alice, acme)
+		if e != nil || !reflect.DeepEqual(actions, []string{"read"}) {
+			t.Fatalf("Actions = %v, %v, want %v", actions, e, []string{"read"})
+		}
+		actions[0] = "mutated by caller"
+	}
+	// Different context facts are a different decision.
+	o.AuthorizeWithContext(alice, "read", acme, []Fact{NewFact("is_public", acme)})
+	if atomic.LoadInt32(&authorizeCalls) != 2 || atomic.LoadInt32(&actionsCalls) != 1 {
+		t.Fatalf("calls = %d authorize, %d actions, want 2, 1", authorizeCalls, actionsCalls)
+	}
+
+	if e := o.Insert(NewFact("has_role", alice, String("member"), acme)); e != nil {
+		t.Fatalf("Insert failed: %v", e)
+	}
+	o.Authorize(alice, "read", acme)
+	if atomic.LoadInt32(&authorizeCalls) != 3 {
+		t.Fatalf("authorize calls = %d after a write, want 3", authorizeCalls)
+	}
+
+	// Parity handles need a fresh request ID from Oso Cloud.
+	o.AuthorizeWithOptions(alice, "read", acme, &AuthorizeOptions{ParityHandle: NewParityHandle()})
+	if atomic.LoadInt32(&authorizeCalls) != 4 {
+		t.Fatalf("authorize calls = %d with a parity handle, want 4", authorizeCalls)
+	}
+
+	stats := o.DecisionCacheStats()
+	expected := DecisionCacheStats{Hits: 4, Misses: 4, Evictions: 0, Size: 1}
+	if stats != expected {
+		t.Fatalf("DecisionCacheStats = %+v, want %+v", stats, expected)
+	}
+}
@@ -0,0 +1,214 @@
+package oso
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// The defaults used for zero-valued fields of [CircuitBreakerConfig].
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitCoolDown         = 30 * time.Second
+	DefaultCircuitHalfOpenProbes   = 1
+)
+
+// The state of the circuit breaker configured with [WithCircuitBreaker].
+type CircuitState int
+
+const (
+	// Calls are sent to Oso Cloud.
+	CircuitClosed CircuitState = iota
+	// Calls that can be answered by the fallback node are sent straight to
+	// it, without trying Oso Cloud first.
+	CircuitOpen
+	// The cool-down has passed, and a limited number of probe calls are sent
+	// to Oso Cloud to check whether it has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerConfig configures [WithCircuitBreaker].
+type CircuitBreakerConfig struct {
+	// The number of consecutive failed calls to Oso Cloud after which the
+	// circuit opens. Defaults to [DefaultCircuitFailureThreshold].
+	FailureThreshold int
+	// How long the circuit stays open before probing Oso Cloud again.
+	// Defaults to [DefaultCircuitCoolDown].
+	CoolDown time.Duration
+	// The number of probe calls that must succeed, one after the other, to
+	// close the circuit again. This is also the number of probes that may be
+	// in flight at once. Defaults to [DefaultCircuitHalfOpenProbes].
+	HalfOpenProbes int
+	// Called whenever the circuit changes state. It must not block.
+	OnStateChange func(from CircuitState, to CircuitState)
+}
+
+// Stop sending calls to Oso Cloud while it's failing, and send calls that are
+// eligible for the fallback node straight there instead, rather than waiting
+// for Oso Cloud to fail each of them first. A call fails if it gets a
+// connection error, a 400 or a 5xx, after retries.
+//
+// Only calls that are eligible for the fallback node are tracked and
+// rerouted, so this has no effect unless [WithFallbackUrls] is also given.
+// Writes are always sent to Oso Cloud.
+func WithCircuitBreaker(config CircuitBreakerConfig) Option {
+	return func(o *clientOptions) {
+		o.circuitBreaker = &config
+	}
+}
+
+// Returns the state of the circuit breaker configured with
+// [WithCircuitBreaker]. Returns [CircuitClosed] if there is none.
+func (c *OsoClientImpl) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.currentState()
+}
+
+// Stands in for the response of Oso Cloud when the circuit is open, to send
+// the call to the fallback node.
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+type circuitBreaker struct {
+	mu             sync.Mutex
+	config         CircuitBreakerConfig
+	state          CircuitState
+	failures       int
+	openedAt       time.Time
+	probesInFlight int
+	probeSuccesses int
+	now            func() time.Time
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+	if config.CoolDown <= 0 {
+		config.CoolDown = DefaultCircuitCoolDown
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = DefaultCircuitHalfOpenProbes
+	}
+	return &circuitBreaker{config: config, now: time.Now}
+}
+
+func (b *circuitBreaker) currentState() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Reports whether a call may be sent to Oso Cloud, and if so whether it is a
+// probe. Every call that is allowed must be followed by record or release. A
+// nil breaker allows every call.
+func (b *circuitBreaker) allowCall() (allowed bool, probe bool) {
+	if b == nil {
+		return true, false
+	}
+	b.mu.Lock()
+	var changed func()
+	defer func() {
+		b.mu.Unlock()
+		if changed != nil {
+			changed()
+		}
+	}()
+
+	switch b.state {
+	case CircuitClosed:
+		return true, false
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.config.CoolDown {
+			return false, false
+		}
+		changed = b.transition(CircuitHalfOpen)
+	}
+	if b.probesInFlight < b.config.HalfOpenProbes {
+		b.probesInFlight++
+		return true, true
+	}
+	return false, false
+}
+
+// Records the outcome of a call that was allowed.
+func (b *circuitBreaker) record(probe bool, success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	var changed func()
+	defer func() {
+		b.mu.Unlock()
+		if changed != nil {
+			changed()
+		}
+	}()
+
+	if probe {
+		b.probesInFlight--
+	}
+	switch b.state {
+	case CircuitClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			changed = b.transition(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if !probe {
+			return
+		}
+		if !success {
+			changed = b.transition(CircuitOpen)
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.config.HalfOpenProbes {
+			changed = b.transition(CircuitClosed)
+		}
+	}
+	// Calls that were started before the circuit opened don't change it.
+}
+
+// Gives back a call that was allowed but didn't finish, eg. because it was
+// cancelled, without counting it as a success or a failure.
+func (b *circuitBreaker) release(probe bool) {
+	if b != nil && probe {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.probesInFlight--
+	}
+}
+
+// Must be called with b.mu held. Returns the state change callback, to be
+// called once b.mu is released.
+func (b *circuitBreaker) transition(to CircuitState) func() {
+	from := b.state
+	b.state = to
+	b.failures = 0
+	b.probeSuccesses = 0
+	if to == CircuitOpen {
+		b.openedAt = b.now()
+	}
+	if b.config.OnStateChange == nil || from == to {
+		return nil
+	}
+	return func() { b.config.OnStateChange(from, to) }
+}
@@ -0,0 +1,103 @@
+package oso
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var primaryCalls, fallbackCalls int32
+	var healthy atomic.Value
+	healthy.Store(false)
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCalls, 1)
+		if !healthy.Load().(bool) {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{"allowed":true,"message":"ok"}`))
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fallbackCalls, 1)
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	defer fallback.Close()
+
+	var mu sync.Mutex
+	var changes []string
+	client, err := New(primary.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		WithFallbackUrl(fallback.URL),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 0}),
+		WithCircuitBreaker(CircuitBreakerConfig{
+			FailureThreshold: 2,
+			CoolDown:         time.Minute,
+			OnStateChange: func(from CircuitState, to CircuitState) {
+				mu.Lock()
+				defer mu.Unlock()
+				changes = append(changes, from.String()+" -> "+to.String())
+			},
+		}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+	authorize := func(expectedPrimary, expectedFallback int32) {
+		t.Helper()
+		allowed, err := client.Authorize(alice, "read", acme)
+		if err != nil || !allowed {
+			t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+		}
+		p, f := atomic.SwapInt32(&primaryCalls, 0), atomic.SwapInt32(&fallbackCalls, 0)
+		if p != expectedPrimary || f != expectedFallback {
+			t.Fatalf("Authorize called primary %d and fallback %d times, want %d and %d", p, f, expectedPrimary, expectedFallback)
+		}
+	}
+
+	authorize(1, 1)
+	authorize(1, 1)
+	if state := client.CircuitState(); state != CircuitOpen {
+		t.Fatalf("CircuitState = %v, want %v", state, CircuitOpen)
+	}
+	authorize(0, 1)
+
+	// Writes can't be answered by the fallback, so they still go to Oso Cloud.
+	if err := client.Insert(NewFact("is_public", acme)); err == nil {
+		t.Fatalf("Insert succeeded, want error")
+	}
+	atomic.SwapInt32(&primaryCalls, 0)
+
+	// A failed probe opens the circuit again.
+	now = now.Add(2 * time.Minute)
+	authorize(1, 1)
+	authorize(0, 1)
+
+	// A successful probe closes it.
+	healthy.Store(true)
+	now = now.Add(2 * time.Minute)
+	authorize(1, 0)
+	authorize(1, 0)
+	if state := client.CircuitState(); state != CircuitClosed {
+		t.Fatalf("CircuitState = %v, want %v", state, CircuitClosed)
+	}
+
+	expected := []string{
+		"closed -> open",
+		"open -> half-open",
+		"half-open -> open",
+		"open -> half-open",
+		"half-open -> closed",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("state changes = %v, want %v", changes, expected)
+	}
+}
@@ -0,0 +1,121 @@
+package oso
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Exercises a single client from many goroutines. Run with -race.
+func TestConcurrentUse(t *testing.T) {
+	var offset int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/batch":
+			w.Header().Set("OsoOffset", strconv.FormatInt(atomic.AddInt64(&offset, 1), 10))
+			w.Write([]byte(`{"message":"ok"}`))
+		case "/api/authorize":
+			w.Write([]byte(`{"allowed":true}`))
+		case "/api/actions":
+			// Fails, so that the circuit breaker and the fallback pool are
+			// exercised too.
+			w.WriteHeader(503)
+		case "/api/policy_metadata":
+			w.Write([]byte(`{"metadata":{"resources":{}}}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results":["read"]}`))
+	}))
+	defer fallback.Close()
+
+	recorder := &fakeRecorder{}
+	client, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		WithFallbackUrls(fallback.URL, fallback.URL),
+		WithFallbackSelection(FallbackLowestLatency),
+		WithDecisionCache(DecisionCacheConfig{TTL: time.Minute, MaxSize: 16}),
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 3, CoolDown: time.Millisecond}),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond, Budget: &RetryBudget{Ratio: 0.5, Burst: 2}}),
+		WithErrorPolicy(FailClosed()),
+		WithMetricsRecorder(recorder))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	const goroutines = 16
+	const iterations = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*iterations*4)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			alice := NewValue("User", "alice"+strconv.Itoa(g%4))
+			for i := 0; i < iterations; i++ {
+				repo := NewValue("Repo", strconv.Itoa(i%8))
+				if _, err := client.Authorize(alice, "read", repo); err != nil {
+					errs <- err
+				}
+				if _, err := client.Actions(alice, repo); err != nil {
+					errs <- err
+				}
+				if _, err := client.InsertWithToken(context.Background(), NewFact("has_role", alice, String("member"), repo)); err != nil {
+					errs <- err
+				}
+				client.ConsistencyToken()
+				client.CircuitState()
+				client.DecisionCacheStats()
+				if i%5 == 0 {
+					client.Health(context.Background())
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("call failed: %v", err)
+	}
+
+	// However the responses to concurrent writes were interleaved, the client
+	// ends up with the token of the latest one.
+	expected := ConsistencyToken(strconv.FormatInt(atomic.LoadInt64(&offset), 10))
+	if token := client.ConsistencyToken(); token != expected {
+		t.Fatalf("ConsistencyToken = %q, want %q", token, expected)
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	writes := 0
+	for _, m := range recorder.requests {
+		if m.Endpoint == "/batch" {
+			writes++
+		}
+	}
+	if writes != goroutines*iterations {
+		t.Fatalf("recorded %d writes, want %d", writes, goroutines*iterations)
+	}
+}
+
+func TestOffsetTrackerKeepsLatest(t *testing.T) {
+	tracker := &offsetTracker{}
+	for _, offset := range []ConsistencyToken{"2", "10", "9", "11"} {
+		tracker.set(offset)
+	}
+	if offset := tracker.get(); offset != "11" {
+		t.Fatalf("offset = %q, want %q", offset, "11")
+	}
+	// Tokens that aren't numeric are taken as they come.
+	tracker.set("abc")
+	tracker.set("9")
+	if offset := tracker.get(); offset != "9" {
+		t.Fatalf("offset = %q, want %q", offset, "9")
+	}
+}
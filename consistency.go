@@ -0,0 +1,103 @@
+package oso
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// A ConsistencyToken identifies a point in Oso Cloud's history of writes. It
+// is returned by writes, and can be passed to reads (possibly made by another
+// client, in another service) with [WithConsistencyToken] to make sure they
+// observe that write.
+//
+// Tokens are opaque. They can be stored and sent between services as strings.
+type ConsistencyToken string
+
+// Tracks the latest token observed by a client, which may be updated by
+// concurrent writes.
+type offsetTracker struct {
+	mu     sync.Mutex
+	offset ConsistencyToken
+}
+
+func (t *offsetTracker) get() ConsistencyToken {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.offset
+}
+
+func (t *offsetTracker) set(offset ConsistencyToken) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Responses to concurrent writes can arrive out of order. Tokens are
+	// opaque, but when they're both numeric, keep the later one.
+	current, err1 := strconv.ParseUint(string(t.offset), 10, 64)
+	next, err2 := strconv.ParseUint(string(offset), 10, 64)
+	if err1 == nil && err2 == nil && next < current {
+		return
+	}
+	t.offset = offset
+}
+
+type consistencyTokenKey struct{}
+
+// Returns a context that makes reads wait for the write identified by token,
+// instead of the latest write made by the client itself. Reads made with this
+// context are never answered from the decision cache.
+func WithConsistencyToken(ctx context.Context, token ConsistencyToken) context.Context {
+	return context.WithValue(ctx, consistencyTokenKey{}, token)
+}
+
+func consistencyTokenFromContext(ctx context.Context) (ConsistencyToken, bool) {
+	token, ok := ctx.Value(consistencyTokenKey{}).(ConsistencyToken)
+	return token, ok && token != ""
+}
+
+type tokenRecorderKey struct{}
+
+// Returns a context that records the token returned by a write made with it.
+func recordConsistencyToken(ctx context.Context) (context.Context, *ConsistencyToken) {
+	token := new(ConsistencyToken)
+	return context.WithValue(ctx, tokenRecorderKey{}, token), token
+}
+
+// Called with the OsoOffset of every successful write.
+func (c *OsoClientImpl) observeOffset(ctx context.Context, offset ConsistencyToken) {
+	if offset != "" {
+		c.offset.set(offset)
+	}
+	if token, ok := ctx.Value(tokenRecorderKey{}).(*ConsistencyToken); ok {
+		*token = offset
+	}
+	if c.cache != nil {
+		c.cache.observeMutation(string(offset))
+	}
+}
+
+// Returns the token of the latest write made by this client, or "" if it
+// hasn't made any. Reads made by this client are always consistent with it.
+func (c *OsoClientImpl) ConsistencyToken() ConsistencyToken {
+	return c.offset.get()
+}
+
+// Like [OsoClientImpl.InsertCtx], but also returns the token of the write.
+func (c *OsoClientImpl) InsertWithToken(ctx context.Context, fact Fact) (ConsistencyToken, error) {
+	ctx, token := recordConsistencyToken(ctx)
+	err := c.InsertCtx(ctx, fact)
+	return *token, err
+}
+
+// Like [OsoClientImpl.DeleteCtx], but also returns the token of the write.
+func (c *OsoClientImpl) DeleteWithToken(ctx context.Context, pattern IntoFactPattern) (ConsistencyToken, error) {
+	ctx, token := recordConsistencyToken(ctx)
+	err := c.DeleteCtx(ctx, pattern)
+	return *token, err
+}
+
+// Like [OsoClientImpl.BatchCtx], but also returns the token of the write.
+func (c *OsoClientImpl) BatchWithToken(ctx context.Context, fn func(BatchTransaction)) (ConsistencyToken, error) {
+	ctx, token := recordConsistencyToken(ctx)
+	err := c.BatchCtx(ctx, fn)
+	return *token, err
+}
@@ -0,0 +1,78 @@
+package oso
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConsistencyTokens(t *testing.T) {
+	var offset int32
+	var mu sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/authorize" {
+			mu.Lock()
+			seen = append(seen, r.Header.Get("OsoOffset"))
+			mu.Unlock()
+			w.Write([]byte(`{"allowed":true}`))
+			return
+		}
+		w.Header().Set("OsoOffset", strconv.Itoa(int(atomic.AddInt32(&offset, 1))))
+		w.Write([]byte(`{"message":"ok"}`))
+	}))
+	defer server.Close()
+
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+	writer, _ := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn")
+	reader, _ := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", WithDecisionCache(DecisionCacheConfig{}))
+
+	if token := writer.ConsistencyToken(); token != "" {
+		t.Fatalf("ConsistencyToken = %q before any write", token)
+	}
+	token, err := writer.InsertWithToken(context.Background(), NewFact("has_role", alice, String("member"), acme))
+	if err != nil || token != "1" {
+		t.Fatalf("InsertWithToken = %q, %v, want %q", token, err, "1")
+	}
+	if latest := writer.ConsistencyToken(); latest != token {
+		t.Fatalf("ConsistencyToken = %q, want %q", latest, token)
+	}
+
+	// The writer's own reads carry its latest token, while another client
+	// only sends one when asked to.
+	writer.Authorize(alice, "read", acme)
+	reader.Authorize(alice, "read", acme)
+	ctx := WithConsistencyToken(context.Background(), token)
+	reader.AuthorizeCtx(ctx, alice, "read", acme, nil)
+	// Reads with an explicit token skip the decision cache.
+	reader.AuthorizeCtx(ctx, alice, "read", acme, nil)
+	expected := []string{"1", "", "1", "1"}
+	if len(seen) != len(expected) {
+		t.Fatalf("saw offsets %q, want %q", seen, expected)
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Fatalf("saw offsets %q, want %q", seen, expected)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			writer.Batch(func(tx BatchTransaction) {
+				tx.Delete(NewFactPattern("has_role", alice, nil, nil))
+			})
+		}()
+	}
+	wg.Wait()
+	if latest := writer.ConsistencyToken(); latest == "" || latest == token {
+		t.Fatalf("ConsistencyToken = %q after concurrent writes", latest)
+	}
+}
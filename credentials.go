@@ -0,0 +1,223 @@
+package oso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A CredentialsProvider supplies the API key the client sends to Oso Cloud.
+// It's consulted for every request, so that keys can be rotated without
+// recreating the client. Implementations must be safe for concurrent use.
+type CredentialsProvider interface {
+	// Returns the API key to send. It's called for every request, so it
+	// should be cheap: providers that fetch keys from elsewhere should cache
+	// them.
+	APIKey(ctx context.Context) (string, error)
+	// Called when Oso Cloud rejects the key returned by APIKey with a 401.
+	// Providers that cache keys should fetch a fresh one. If the key changes,
+	// the request is sent again with the new key.
+	Refresh(ctx context.Context) error
+}
+
+// Get the API key from the given provider, instead of using the one passed to
+// [New], which may then be empty.
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(o *clientOptions) {
+		o.credentials = provider
+	}
+}
+
+type staticCredentials string
+
+// A provider that always returns the given key. This is what a client uses by
+// default, with the key passed to [New].
+func StaticCredentials(apiKey string) CredentialsProvider {
+	return staticCredentials(apiKey)
+}
+
+func (s staticCredentials) APIKey(context.Context) (string, error) {
+	return string(s), nil
+}
+
+func (s staticCredentials) Refresh(context.Context) error {
+	return nil
+}
+
+type envCredentials string
+
+// A provider that reads the key from the given environment variable for
+// every request.
+func EnvCredentials(name string) CredentialsProvider {
+	return envCredentials(name)
+}
+
+func (e envCredentials) APIKey(context.Context) (string, error) {
+	key := strings.TrimSpace(os.Getenv(string(e)))
+	if key == "" {
+		return "", fmt.Errorf("oso: environment variable %s is not set", string(e))
+	}
+	return key, nil
+}
+
+func (e envCredentials) Refresh(context.Context) error {
+	return nil
+}
+
+// How often a [FileCredentials] provider checks whether its file has changed.
+const fileCredentialsCheckInterval = time.Second
+
+type fileCredentials struct {
+	path    string
+	mu      sync.Mutex
+	key     string
+	modTime time.Time
+	size    int64
+	checked time.Time
+	now     func() time.Time
+}
+
+// A provider that reads the key from the given file, eg. one mounted from a
+// secrets manager. The file is read again whenever it changes, or when Oso
+// Cloud rejects the key. Surrounding whitespace is ignored.
+func FileCredentials(path string) CredentialsProvider {
+	return &fileCredentials{path: path, now: time.Now}
+}
+
+func (f *fileCredentials) APIKey(context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	if f.key != "" && now.Sub(f.checked) < fileCredentialsCheckInterval {
+		return f.key, nil
+	}
+	f.checked = now
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", err
+	}
+	if f.key != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.key, nil
+	}
+	if err := f.read(); err != nil {
+		return "", err
+	}
+	return f.key, nil
+}
+
+func (f *fileCredentials) Refresh(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checked = f.now()
+	return f.read()
+}
+
+// Must be called with f.mu held.
+func (f *fileCredentials) read() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	contents, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	key := strings.TrimSpace(string(contents))
+	if key == "" {
+		return fmt.Errorf("oso: credentials file %s is empty", f.path)
+	}
+	f.key, f.modTime, f.size = key, info.ModTime(), info.Size()
+	return nil
+}
+
+type funcCredentials struct {
+	fetch func(ctx context.Context) (string, error)
+	mu    sync.Mutex
+	key   string
+}
+
+// A provider that gets the key by calling fetch, eg. to read it from a
+// secrets manager. The key is cached until Oso Cloud rejects it, when fetch is
+// called again.
+func CredentialsFunc(fetch func(ctx context.Context) (string, error)) CredentialsProvider {
+	return &funcCredentials{fetch: fetch}
+}
+
+func (f *funcCredentials) APIKey(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == "" {
+		if err := f.refresh(ctx); err != nil {
+			return "", err
+		}
+	}
+	return f.key, nil
+}
+
+func (f *funcCredentials) Refresh(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refresh(ctx)
+}
+
+// Must be called with f.mu held.
+func (f *funcCredentials) refresh(ctx context.Context) error {
+	key, err := f.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return errors.New("oso: credentials callback returned an empty API key")
+	}
+	f.key = key
+	return nil
+}
+
+// Called when Oso Cloud rejects the API key the request was sent with.
+// Refreshes the credentials, and if that yields a different key, sends the
+// request again with it. Otherwise returns res as it is.
+//
+// Concurrent requests rejected with the same key share a single refresh: if
+// the provider's key has already changed by the time a request gets here, it
+// is sent again with that key without refreshing.
+func (c *OsoClientImpl) retryUnauthorized(ctx context.Context, requestData RequestData, req *http.Request, res *http.Response) (*http.Response, error) {
+	sentKey := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	key, err := c.refreshedAPIKey(ctx, sentKey)
+	if err != nil {
+		c.logger.log(levelWarn, "oso: failed to refresh credentials", "endpoint", requestData.path, "error", err)
+		return res, nil
+	}
+	if key == sentKey {
+		return res, nil
+	}
+	res.Body.Close()
+	c.logger.log(levelInfo, "oso: retrying request with refreshed credentials", "endpoint", requestData.path)
+	req, err = c.apiCall(ctx, requestData)
+	if err != nil {
+		return nil, err
+	}
+	return do(c.httpClient, req, requestData.stream)
+}
+
+// Returns the provider's API key, refreshing the credentials first unless the
+// key already differs from rejectedKey. Refreshes are serialized, so requests
+// waiting on one see its result instead of starting another.
+func (c *OsoClientImpl) refreshedAPIKey(ctx context.Context, rejectedKey string) (string, error) {
+	if key, err := c.credentials.APIKey(ctx); err != nil || key != rejectedKey {
+		return key, err
+	}
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if key, err := c.credentials.APIKey(ctx); err != nil || key != rejectedKey {
+		return key, err
+	}
+	if err := c.credentials.Refresh(ctx); err != nil {
+		return "", err
+	}
+	return c.credentials.APIKey(ctx)
+}
@@ -0,0 +1,184 @@
+package oso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Serves /api/authorize to requests that carry the current key, which can be
+// rotated.
+func newRotatingKeyServer(key string) (*httptest.Server, *atomic.Value, *int32) {
+	current := &atomic.Value{}
+	current.Store(key)
+	var unauthorized int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+current.Load().(string) {
+			atomic.AddInt32(&unauthorized, 1)
+			w.WriteHeader(401)
+			w.Write([]byte(`{"message":"invalid API key"}`))
+			return
+		}
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	return server, current, &unauthorized
+}
+
+func TestCredentialsFunc(t *testing.T) {
+	server, current, unauthorized := newRotatingKeyServer("key1")
+	defer server.Close()
+
+	var fetches int32
+	provider := CredentialsFunc(func(context.Context) (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		return current.Load().(string), nil
+	})
+	o, err := New(server.URL, "", WithCredentialsProvider(provider))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+
+	for i := 0; i < 2; i++ {
+		if allowed, err := o.Authorize(alice, "read", acme); err != nil || !allowed {
+			t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("fetched the key %d times, want 1", n)
+	}
+
+	// After a rotation, the first request is rejected, and retried with the
+	// new key.
+	current.Store("key2")
+	if allowed, err := o.Authorize(alice, "read", acme); err != nil || !allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+	}
+	if n, u := atomic.LoadInt32(&fetches), atomic.LoadInt32(unauthorized); n != 2 || u != 1 {
+		t.Fatalf("fetched the key %d times and got %d 401s, want 2 and 1", n, u)
+	}
+}
+
+func TestCredentialsConcurrentUnauthorized(t *testing.T) {
+	server, current, unauthorized := newRotatingKeyServer("key1")
+	defer server.Close()
+	var fetches int32
+	o, err := New(server.URL, "", WithCredentialsProvider(CredentialsFunc(func(context.Context) (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(10 * time.Millisecond)
+		return current.Load().(string), nil
+	})))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	alice, acme := NewValue("User", "alice"), NewValue("Repo", "acme")
+	if _, err := o.Authorize(alice, "read", acme); err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+
+	// Requests rejected with the old key all retry with the new one, but
+	// only one of them fetches it.
+	current.Store("key2")
+	const n = 20
+	var start, done sync.WaitGroup
+	start.Add(1)
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			start.Wait()
+			if allowed, err := o.Authorize(alice, "read", acme); err != nil || !allowed {
+				errs <- fmt.Errorf("Authorize = %t, %v, want %t", allowed, err, true)
+			}
+		}()
+	}
+	start.Done()
+	done.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if f := atomic.LoadInt32(&fetches); f != 2 {
+		t.Fatalf("fetched the key %d times after %d 401s, want 2", f, atomic.LoadInt32(unauthorized))
+	}
+}
+
+func TestCredentialsUnauthorized(t *testing.T) {
+	server, _, unauthorized := newRotatingKeyServer("key1")
+	defer server.Close()
+
+	// A refresh that doesn't change the key doesn't retry the request.
+	o, err := New(server.URL, "wrong")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	_, err = o.Authorize(NewValue("User", "alice"), "read", NewValue("Repo", "acme"))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 {
+		t.Fatalf("Authorize error = %v, want a 401 APIError", err)
+	}
+	if u := atomic.LoadInt32(unauthorized); u != 1 {
+		t.Fatalf("got %d 401s, want 1", u)
+	}
+
+	o, err = New(server.URL, "", WithCredentialsProvider(EnvCredentials("OSO_TEST_UNSET_API_KEY")))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := o.Authorize(NewValue("User", "alice"), "read", NewValue("Repo", "acme")); err == nil {
+		t.Fatalf("Authorize succeeded without an API key, want error")
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	server, current, _ := newRotatingKeyServer("key1")
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(path, []byte("key1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	provider := FileCredentials(path).(*fileCredentials)
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+	o, err := New(server.URL, "", WithCredentialsProvider(provider))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+	if allowed, err := o.Authorize(alice, "read", acme); err != nil || !allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+	}
+
+	// A changed file is picked up once the check interval has passed...
+	if err := os.WriteFile(path, []byte("key2-longer\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := provider.APIKey(context.Background()); key != "key1" {
+		t.Fatalf("APIKey = %q before the check interval, want %q", key, "key1")
+	}
+	now = now.Add(fileCredentialsCheckInterval)
+	if key, _ := provider.APIKey(context.Background()); key != "key2-longer" {
+		t.Fatalf("APIKey = %q, want %q", key, "key2-longer")
+	}
+
+	// ...or straight away if Oso Cloud rejects the key.
+	current.Store("key3")
+	if err := os.WriteFile(path, []byte("key3"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if allowed, err := o.Authorize(alice, "read", acme); err != nil || !allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+	}
+}
@@ -0,0 +1,87 @@
+package oso
+
+import (
+	"context"
+)
+
+// An ErrorPolicy decides the outcome of an authorization check that failed
+// with err because Oso Cloud was unavailable (see [IsUnavailable]), eg.
+// because it couldn't be reached or the check timed out. If it returns
+// handled, the check returns allowed instead of the error, and the decision
+// is reported as degraded.
+type ErrorPolicy func(actor Value, action string, resource Value, err error) (allowed bool, handled bool)
+
+// Deny every check that fails.
+func FailClosed() ErrorPolicy {
+	return func(Value, string, Value, error) (bool, bool) {
+		return false, true
+	}
+}
+
+// Allow checks of the given actions that fail, and deny all others.
+func FailOpen(actions ...string) ErrorPolicy {
+	allowlist := make(map[string]bool, len(actions))
+	for _, action := range actions {
+		allowlist[action] = true
+	}
+	return func(_ Value, action string, _ Value, _ error) (bool, bool) {
+		return allowlist[action], true
+	}
+}
+
+// Decide the outcome of authorization checks that fail because Oso Cloud is
+// unavailable with the given policy, instead of returning their errors. Other
+// errors, like a rejected API key, an invalid argument or the caller's context
+// ending, are always returned. Applies to Authorize and AuthorizeResources,
+// and their variants.
+//
+//	client, err := oso.New(url, apiKey, oso.WithErrorPolicy(oso.FailOpen("read")))
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(o *clientOptions) {
+		o.errorPolicy = policy
+	}
+}
+
+// Describes how an authorization check reached its decision. Set
+// [AuthorizeOptions].Outcome to have it filled in.
+type AuthorizeOutcome struct {
+	// Whether the decision was made by the client's [ErrorPolicy], because
+	// Oso Cloud couldn't answer.
+	Degraded bool
+	// The error that caused a degraded decision.
+	Cause error
+}
+
+func (o *AuthorizeOutcome) set(degraded bool, cause error) {
+	if o != nil {
+		o.Degraded = degraded
+		o.Cause = cause
+	}
+}
+
+// Bounds ctx by the timeout of options, if it has one.
+func withAuthorizeTimeout(ctx context.Context, options *AuthorizeOptions) (context.Context, context.CancelFunc) {
+	if options.Timeout > 0 {
+		return context.WithTimeout(ctx, options.Timeout)
+	}
+	return ctx, func() {}
+}
+
+// Applies the error policy to a check, made with the caller's ctx, that failed
+// with err. Returns false if there is no policy, Oso Cloud wasn't unavailable
+// or the caller gave up on the check, or the policy didn't handle the error.
+func (c *OsoClientImpl) degradedDecision(ctx context.Context, actor Value, action string, resource Value, err error) (allowed bool, handled bool) {
+	if c.errorPolicy == nil || ctx.Err() != nil || !IsUnavailable(err) {
+		return false, false
+	}
+	allowed, handled = c.errorPolicy(actor, action, resource, err)
+	if handled {
+		c.observeDegradedDecision(action, allowed)
+		c.logger.log(levelWarn, "oso: degraded authorization decision",
+			"action", action,
+			"resource_type", resource.Type,
+			"allowed", allowed,
+			"error", err)
+	}
+	return allowed, handled
+}
@@ -0,0 +1,203 @@
+package oso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *fakeLogger) Printf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestErrorPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+		w.Write([]byte(`{"message":"unavailable"}`))
+	}))
+	defer server.Close()
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+	other := NewValue("Repo", "other")
+
+	o, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", WithRetryPolicy(RetryPolicy{MaxRetries: 0}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := o.Authorize(alice, "read", acme); err == nil {
+		t.Fatalf("Authorize succeeded without an error policy, want error")
+	}
+
+	recorder := &fakeRecorder{}
+	logger := &fakeLogger{}
+	o, err = New(server.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		WithRetryPolicy(RetryPolicy{MaxRetries: 0}),
+		WithErrorPolicy(FailOpen("read")),
+		WithMetricsRecorder(recorder),
+		WithLogger(logger))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	outcome := &AuthorizeOutcome{}
+	allowed, err := o.AuthorizeWithOptions(alice, "read", acme, &AuthorizeOptions{Outcome: outcome})
+	if err != nil || !allowed {
+		t.Fatalf("Authorize(read) = %t, %v, want %t", allowed, err, true)
+	}
+	var apiErr *APIError
+	if !outcome.Degraded || !errors.As(outcome.Cause, &apiErr) || apiErr.StatusCode != 503 {
+		t.Fatalf("unexpected outcome: %+v", outcome)
+	}
+	allowed, err = o.AuthorizeWithOptions(alice, "write", acme, &AuthorizeOptions{Outcome: outcome})
+	if err != nil || allowed || !outcome.Degraded {
+		t.Fatalf("Authorize(write) = %t, %v, %+v, want a degraded denial", allowed, err, outcome)
+	}
+
+	results, err := o.AuthorizeResources(alice, "read", []Value{acme, other}, nil)
+	if err != nil || !reflect.DeepEqual(results, []Value{acme, other}) {
+		t.Fatalf("AuthorizeResources = %v, %v, want %v", results, err, []Value{acme, other})
+	}
+
+	// Invalid arguments are still reported.
+	if _, err := o.Authorize(Value{}, "read", acme); err == nil {
+		t.Fatalf("Authorize with an invalid actor succeeded, want error")
+	}
+
+	expected := []DecisionMetrics{
+		{Action: "read", Allowed: true, Degraded: true},
+		{Action: "write", Allowed: false, Degraded: true},
+		{Action: "read", Allowed: true, Degraded: true},
+		{Action: "read", Allowed: true, Degraded: true},
+	}
+	if !reflect.DeepEqual(recorder.decisions, expected) {
+		t.Fatalf("decisions = %+v, want %+v", recorder.decisions, expected)
+	}
+	warnings := 0
+	for _, line := range logger.lines {
+		if strings.Contains(line, "degraded authorization decision") {
+			warnings++
+		}
+	}
+	if warnings != len(expected) {
+		t.Fatalf("logged %d degraded decisions, want %d: %q", warnings, len(expected), logger.lines)
+	}
+}
+
+func TestAuthorizeTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	defer server.Close()
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+
+	o, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", WithErrorPolicy(
+		func(_ Value, _ string, _ Value, err error) (bool, bool) {
+			return false, errors.Is(err, context.DeadlineExceeded)
+		}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	outcome := &AuthorizeOutcome{}
+	start := time.Now()
+	allowed, err := o.AuthorizeWithOptions(alice, "read", acme, &AuthorizeOptions{Timeout: 20 * time.Millisecond, Outcome: outcome})
+	if err != nil || allowed || !outcome.Degraded {
+		t.Fatalf("Authorize = %t, %v, %+v, want a degraded denial", allowed, err, outcome)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Authorize took %v, want it bounded by the timeout", elapsed)
+	}
+
+	allowed, err = o.AuthorizeWithOptions(alice, "read", acme, &AuthorizeOptions{Outcome: outcome})
+	if err != nil || !allowed || outcome.Degraded {
+		t.Fatalf("Authorize = %t, %v, %+v, want an allow that isn't degraded", allowed, err, outcome)
+	}
+}
+
+func TestErrorPolicyOnlyWhenUnavailable(t *testing.T) {
+	status := make(chan int, 1)
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case code := <-status:
+			w.WriteHeader(code)
+			w.Write([]byte(`{"message":"rejected"}`))
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+
+	o, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		WithRetryPolicy(RetryPolicy{MaxRetries: 0}),
+		WithErrorPolicy(FailOpen("read")))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	outcome := &AuthorizeOutcome{}
+	status <- 401
+	allowed, err := o.AuthorizeWithOptions(alice, "read", acme, &AuthorizeOptions{Outcome: outcome})
+	if !errors.Is(err, ErrUnauthorized) || allowed || outcome.Degraded {
+		t.Fatalf("Authorize with a rejected API key = %t, %v, %+v, want ErrUnauthorized", allowed, err, outcome)
+	}
+	status <- 400
+	if _, err := o.AuthorizeResources(alice, "read", []Value{acme}, nil); err == nil {
+		t.Fatalf("AuthorizeResources with a 400 succeeded, want error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	allowed, err = o.AuthorizeCtx(ctx, alice, "read", acme, &AuthorizeOptions{Outcome: outcome})
+	if !errors.Is(err, context.Canceled) || allowed || outcome.Degraded {
+		t.Fatalf("Authorize with a canceled context = %t, %v, %+v, want context.Canceled", allowed, err, outcome)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := o.AuthorizeCtx(ctx, alice, "read", acme, nil); err == nil {
+		t.Fatalf("Authorize past the caller's deadline succeeded, want error")
+	}
+}
+
+func TestErrorPolicyIsUnavailable(t *testing.T) {
+	for _, c := range []struct {
+		err      error
+		expected bool
+	}{
+		{&APIError{StatusCode: 503}, true},
+		{&APIError{StatusCode: 500}, true},
+		{&APIError{StatusCode: 401}, false},
+		{&APIError{StatusCode: 429}, false},
+		{errCircuitOpen, true},
+		{fmt.Errorf("POST /api/authorize: %w", context.DeadlineExceeded), true},
+		{context.Canceled, false},
+		{invalidValueError("Value must have a non-empty ID"), false},
+		{nil, false},
+	} {
+		if actual := IsUnavailable(c.err); actual != c.expected {
+			t.Errorf("IsUnavailable(%v) = %t, want %t", c.err, actual, c.expected)
+		}
+	}
+}
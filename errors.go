@@ -0,0 +1,106 @@
+package oso
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Sentinel errors which can be matched with [errors.Is]. Errors returned by
+// Oso Cloud are reported as an [*APIError], which matches the sentinel for its
+// status code:
+//
+//	allowed, err := oso.Authorize(actor, "read", resource)
+//	if errors.Is(err, ErrRateLimited) {
+//		// back off
+//	}
+var (
+	// Oso Cloud rejected the API key (HTTP 401).
+	ErrUnauthorized = errors.New("unauthorized")
+	// The requested resource doesn't exist in Oso Cloud (HTTP 404).
+	ErrNotFound = errors.New("not found")
+	// Oso Cloud is rate limiting this client (HTTP 429).
+	ErrRateLimited = errors.New("rate limited")
+	// The request body is too large, either for this client or for Oso Cloud (HTTP 413).
+	ErrPayloadTooLarge = errors.New("request payload too large")
+	// A [Value], [ValueOfType] or [Fact] passed to the client is invalid, eg.
+	// it has an empty Type or ID, in which case it's returned before any
+	// request is made; or Oso Cloud rejected the request as invalid (HTTP 400
+	// or 422).
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// An APIError is returned when Oso Cloud (or the fallback service) responds
+// with a non-2xx status code. Use [errors.As] to inspect it:
+//
+//	var apiErr *APIError
+//	if errors.As(err, &apiErr) {
+//		log.Printf("Oso request %s failed with %d", apiErr.RequestID, apiErr.StatusCode)
+//	}
+type APIError struct {
+	// The HTTP status code of the response.
+	StatusCode int
+	// The error message returned by Oso Cloud.
+	Message string
+	// The value of the X-Request-ID response header, useful when contacting Oso support.
+	RequestID string
+	// The API endpoint that was called, eg. "/authorize".
+	Endpoint string
+	// Whether the request was also sent to the fallback URL. If so, the other
+	// fields describe the fallback's response.
+	FallbackAttempted bool
+}
+
+func (e *APIError) Error() string {
+	return "Oso Cloud error: " + e.Message + " (Request ID: " + e.RequestID + ")"
+}
+
+// Reports whether the status code of e corresponds to the given sentinel error.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrPayloadTooLarge:
+		return e.StatusCode == http.StatusRequestEntityTooLarge
+	case ErrInvalidValue:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
+
+// An invalidValueError is returned when client-side validation of an argument
+// fails. It keeps its own message but matches [ErrInvalidValue].
+type invalidValueError string
+
+func (e invalidValueError) Error() string {
+	return string(e)
+}
+
+func (e invalidValueError) Unwrap() error {
+	return ErrInvalidValue
+}
+
+// Reports whether err means that Oso Cloud couldn't answer a request: it
+// couldn't be reached, responded with a 5xx status code, timed out, or the
+// client's circuit breaker is open. Errors that would recur if the request
+// were repeated, like a rejected API key or an invalid argument, and the
+// cancellation of the caller's context, aren't.
+func IsUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	if errors.Is(err, errCircuitOpen) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
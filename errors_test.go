@@ -0,0 +1,103 @@
+package oso
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIErrorStatusCodes(t *testing.T) {
+	testCases := []struct {
+		status   int
+		body     string
+		sentinel error
+		message  string
+	}{
+		{401, `{"message": "invalid API key"}`, ErrUnauthorized, "invalid API key"},
+		{404, `{"message": "no such thing"}`, ErrNotFound, "no such thing"},
+		{413, `{"message": "too big"}`, ErrPayloadTooLarge, "too big"},
+		{429, "slow down\n", ErrRateLimited, "slow down"},
+		{400, `{"message": "unknown action"}`, ErrInvalidValue, "unknown action"},
+		{422, `{"message": "bad fact"}`, ErrInvalidValue, "bad fact"},
+	}
+	for _, tc := range testCases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-ID", "req-123")
+			w.WriteHeader(tc.status)
+			w.Write([]byte(tc.body))
+		}))
+
+		o, _ := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", WithRetryPolicy(RetryPolicy{MaxRetries: 0}))
+		_, err := o.Authorize(NewValue("User", "alice"), "read", NewValue("Repo", "acme"))
+		server.Close()
+
+		if !errors.Is(err, tc.sentinel) {
+			t.Fatalf("status %d: errors.Is(%v, %v) = false", tc.status, err, tc.sentinel)
+		}
+		for _, other := range []error{ErrUnauthorized, ErrNotFound, ErrPayloadTooLarge, ErrRateLimited, ErrInvalidValue} {
+			if other != tc.sentinel && errors.Is(err, other) {
+				t.Fatalf("status %d: errors.Is(%v, %v) = true", tc.status, err, other)
+			}
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("status %d: error %v is not an *APIError", tc.status, err)
+		}
+		expected := APIError{StatusCode: tc.status, Message: tc.message, RequestID: "req-123", Endpoint: "/authorize"}
+		if *apiErr != expected {
+			t.Fatalf("status %d: got %+v, want %+v", tc.status, *apiErr, expected)
+		}
+		if !strings.HasPrefix(err.Error(), "Oso Cloud error: ") {
+			t.Fatalf("status %d: unexpected message %q", tc.status, err.Error())
+		}
+	}
+}
+
+func TestAPIErrorFallbackAttempted(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"message": "primary failed"}`))
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"message": "fallback failed"}`))
+	}))
+	defer fallback.Close()
+
+	o := NewClientWithFallbackUrl(primary.URL, "e_0123456789_12345_osotesttoken01xiIn", fallback.URL)
+	_, err := o.Actions(NewValue("User", "alice"), NewValue("Repo", "acme"))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.FallbackAttempted || apiErr.Message != "fallback failed" {
+		t.Fatalf("Actions error = %#v, want fallback *APIError", err)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("errors.Is(%v, ErrNotFound) = false", err)
+	}
+}
+
+func TestInvalidValueErrors(t *testing.T) {
+	// None of these should make it to the server.
+	o := NewClient("http://localhost:6000", "e_0123456789_12345_osotesttoken01xiIn")
+
+	err := o.Insert(NewFact("has_role", NewValue("", "alice"), String("member"), NewValue("Repo", "acme")))
+	if !errors.Is(err, ErrInvalidValue) || err.Error() != "Value must have a non-empty Type" {
+		t.Fatalf("Insert error = %v, want ErrInvalidValue", err)
+	}
+	err = o.Delete(NewFactPattern("has_role", NewValueOfType(""), nil, nil))
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("Delete error = %v, want ErrInvalidValue", err)
+	}
+	_, err = o.AuthorizeWithContext(NewValue("User", "alice"), "read", NewValue("Repo", "acme"), []Fact{
+		NewFact("has_role", NewValue("User", ""), String("member"), NewValue("Repo", "acme")),
+	})
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("AuthorizeWithContext error = %v, want ErrInvalidValue", err)
+	}
+	err = o.Insert(NewFact("has_role", NewValue("User", "alice"), String(strings.Repeat("a", maxBodySize))))
+	if !errors.Is(err, ErrPayloadTooLarge) || !strings.HasPrefix(err.Error(), "request payload too large") {
+		t.Fatalf("Insert error = %v, want ErrPayloadTooLarge", err)
+	}
+}
@@ -0,0 +1,135 @@
+package oso
+
+import (
+	"sync"
+	"time"
+)
+
+// How a client chooses between fallback nodes given with [WithFallbackUrls].
+type FallbackSelection int
+
+const (
+	// Take turns, starting each call at the next fallback node.
+	FallbackRoundRobin FallbackSelection = iota
+	// Prefer the fallback node that has recently responded fastest.
+	FallbackLowestLatency
+)
+
+// How long a fallback node that failed is tried only after the others.
+const fallbackCoolDown = 10 * time.Second
+
+// Send eligible requests to the given fallback nodes if Oso Cloud is
+// unavailable. If a fallback node fails, the call fails over to the next one,
+// and the failed node is avoided for a while. Replaces any URL given with
+// [WithFallbackUrl].
+//
+// See https://www.osohq.com/docs/guides/production/deploy-fallback-node
+func WithFallbackUrls(fallbackUrls ...string) Option {
+	return func(o *clientOptions) {
+		o.fallbackUrls = nil
+		for _, url := range fallbackUrls {
+			if url != "" {
+				o.fallbackUrls = append(o.fallbackUrls, url)
+			}
+		}
+	}
+}
+
+// Choose between fallback nodes as given. Defaults to [FallbackRoundRobin].
+func WithFallbackSelection(selection FallbackSelection) Option {
+	return func(o *clientOptions) {
+		o.fallbackSelection = selection
+	}
+}
+
+type fallbackNode struct {
+	url string
+	// Guarded by the pool's mutex.
+	latency    time.Duration
+	avoidUntil time.Time
+}
+
+// The fallback nodes of a client, and what it has learned about them.
+type fallbackPool struct {
+	mu        sync.Mutex
+	nodes     []*fallbackNode
+	selection FallbackSelection
+	next      int
+	now       func() time.Time
+}
+
+func newFallbackPool(urls []string, selection FallbackSelection) *fallbackPool {
+	if len(urls) == 0 {
+		return nil
+	}
+	pool := &fallbackPool{selection: selection, now: time.Now}
+	for _, url := range urls {
+		pool.nodes = append(pool.nodes, &fallbackNode{url: url})
+	}
+	return pool
+}
+
+func (p *fallbackPool) urls() []string {
+	urls := make([]string, 0, len(p.nodes))
+	for _, node := range p.nodes {
+		urls = append(urls, node.url)
+	}
+	return urls
+}
+
+// Returns the nodes in the order a call should try them: nodes that failed
+// recently go last, and the others are ordered by the selection strategy.
+func (p *fallbackPool) order() []*fallbackNode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ordered := make([]*fallbackNode, 0, len(p.nodes))
+	start := 0
+	if p.selection == FallbackRoundRobin {
+		start = p.next
+		p.next = (p.next + 1) % len(p.nodes)
+	}
+	for i := range p.nodes {
+		ordered = append(ordered, p.nodes[(start+i)%len(p.nodes)])
+	}
+	if p.selection == FallbackLowestLatency {
+		// Insertion sort, which is stable and fine for a handful of nodes.
+		// Nodes that haven't been measured yet are tried first, so that
+		// they get measured.
+		for i := 1; i < len(ordered); i++ {
+			for j := i; j > 0 && ordered[j].latency < ordered[j-1].latency; j-- {
+				ordered[j], ordered[j-1] = ordered[j-1], ordered[j]
+			}
+		}
+	}
+
+	now := p.now()
+	healthy := ordered[:0:0]
+	avoided := []*fallbackNode{}
+	for _, node := range ordered {
+		if now.Before(node.avoidUntil) {
+			avoided = append(avoided, node)
+		} else {
+			healthy = append(healthy, node)
+		}
+	}
+	return append(healthy, avoided...)
+}
+
+func (p *fallbackPool) recordSuccess(node *fallbackNode, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if node.latency == 0 {
+		node.latency = latency
+	} else {
+		// An exponentially weighted moving average.
+		node.latency = (4*node.latency + latency) / 5
+	}
+	node.avoidUntil = time.Time{}
+}
+
+func (p *fallbackPool) recordFailure(node *fallbackNode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	node.avoidUntil = p.now().Add(fallbackCoolDown)
+}
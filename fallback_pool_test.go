@@ -0,0 +1,154 @@
+package oso
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fallbackNodeServer struct {
+	*httptest.Server
+	calls   int32
+	healthy atomic.Value
+}
+
+func newFallbackNodeServer(delay time.Duration) *fallbackNodeServer {
+	s := &fallbackNodeServer{}
+	s.healthy.Store(true)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.calls, 1)
+		time.Sleep(delay)
+		if !s.healthy.Load().(bool) {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	return s
+}
+
+func (s *fallbackNodeServer) takeCalls() int32 {
+	return atomic.SwapInt32(&s.calls, 0)
+}
+
+func newFallbackPoolClient(t *testing.T, nodes []*fallbackNodeServer, selection FallbackSelection) *OsoClientImpl {
+	t.Helper()
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	}))
+	t.Cleanup(primary.Close)
+	urls := []string{}
+	for _, node := range nodes {
+		urls = append(urls, node.URL)
+	}
+	o, err := New(primary.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		WithFallbackUrls(urls...),
+		WithFallbackSelection(selection),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 0}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return o
+}
+
+func authorizeViaFallback(t *testing.T, client *OsoClientImpl) {
+	t.Helper()
+	allowed, err := client.Authorize(NewValue("User", "alice"), "read", NewValue("Repo", "acme"))
+	if err != nil || !allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+	}
+}
+
+func TestFallbackRoundRobin(t *testing.T) {
+	a, b := newFallbackNodeServer(0), newFallbackNodeServer(0)
+	defer a.Close()
+	defer b.Close()
+	client := newFallbackPoolClient(t, []*fallbackNodeServer{a, b}, FallbackRoundRobin)
+
+	for i := 0; i < 4; i++ {
+		authorizeViaFallback(t, client)
+	}
+	if ca, cb := a.takeCalls(), b.takeCalls(); ca != 2 || cb != 2 {
+		t.Fatalf("fallback nodes called %d and %d times, want 2 and 2", ca, cb)
+	}
+}
+
+func TestFallbackFailover(t *testing.T) {
+	a, b := newFallbackNodeServer(0), newFallbackNodeServer(0)
+	defer a.Close()
+	defer b.Close()
+	client := newFallbackPoolClient(t, []*fallbackNodeServer{a, b}, FallbackRoundRobin)
+	now := time.Now()
+	client.fallbacks.now = func() time.Time { return now }
+
+	a.healthy.Store(false)
+	authorizeViaFallback(t, client)
+	if ca, cb := a.takeCalls(), b.takeCalls(); ca != 1 || cb != 1 {
+		t.Fatalf("fallback nodes called %d and %d times, want 1 and 1", ca, cb)
+	}
+
+	// The failed node is avoided until the cool-down has passed, even when
+	// it's its turn.
+	authorizeViaFallback(t, client)
+	authorizeViaFallback(t, client)
+	if ca, cb := a.takeCalls(), b.takeCalls(); ca != 0 || cb != 2 {
+		t.Fatalf("fallback nodes called %d and %d times, want 0 and 2", ca, cb)
+	}
+
+	a.healthy.Store(true)
+	now = now.Add(fallbackCoolDown)
+	authorizeViaFallback(t, client)
+	authorizeViaFallback(t, client)
+	if ca, cb := a.takeCalls(), b.takeCalls(); ca != 1 || cb != 1 {
+		t.Fatalf("fallback nodes called %d and %d times, want 1 and 1", ca, cb)
+	}
+
+	// If every node fails, the error of the last one is returned.
+	a.healthy.Store(false)
+	b.healthy.Store(false)
+	_, err := client.Authorize(NewValue("User", "alice"), "read", NewValue("Repo", "acme"))
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != 503 {
+		t.Fatalf("Authorize error = %v, want a 503 APIError", err)
+	}
+}
+
+func TestFallbackLowestLatency(t *testing.T) {
+	slow, fast := newFallbackNodeServer(50*time.Millisecond), newFallbackNodeServer(0)
+	defer slow.Close()
+	defer fast.Close()
+	client := newFallbackPoolClient(t, []*fallbackNodeServer{slow, fast}, FallbackLowestLatency)
+
+	// Nodes that haven't been measured are tried first.
+	client.fallbacks.nodes[1].latency = time.Millisecond
+	authorizeViaFallback(t, client)
+	if cs, cf := slow.takeCalls(), fast.takeCalls(); cs != 1 || cf != 0 {
+		t.Fatalf("fallback nodes called %d and %d times, want 1 and 0", cs, cf)
+	}
+
+	for i := 0; i < 3; i++ {
+		authorizeViaFallback(t, client)
+	}
+	if cs, cf := slow.takeCalls(), fast.takeCalls(); cs != 0 || cf != 3 {
+		t.Fatalf("fallback nodes called %d and %d times, want 0 and 3", cs, cf)
+	}
+}
+
+func TestFallbackHealth(t *testing.T) {
+	a, b := newFallbackNodeServer(0), newFallbackNodeServer(0)
+	defer a.Close()
+	defer b.Close()
+	client := newFallbackPoolClient(t, []*fallbackNodeServer{a, b}, FallbackRoundRobin)
+
+	b.healthy.Store(false)
+	report := client.Health(context.Background())
+	if len(report.Fallbacks) != 2 || report.Fallbacks[0].URL != a.URL || !report.Fallbacks[0].Healthy() ||
+		report.Fallbacks[1].URL != b.URL || report.Fallbacks[1].Healthy() {
+		t.Fatalf("unexpected fallback health: %+v", report.Fallbacks)
+	}
+	if !report.Ready() {
+		t.Fatalf("Ready = false, want true")
+	}
+}
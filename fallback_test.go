@@ -1,405 +1,227 @@
-package oso
+package oso_test
 
 import (
-	"fmt"
-	"net/http"
-	"net/url"
-	"strings"
-	"sync"
 	"testing"
 
-	"github.com/dhuan/mock/pkg/mock"
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"github.com/osohq/go-oso-cloud/v2/osotest"
 )
 
-const (
-	MOCK_SERVER_HOST = "localhost"
-	MOCK_SERVER_PORT = "4000"
-)
-
-type TestServerType int
+const fallbackTestAPIKey = "e_0123456789_12345_osotesttoken01xiIn"
 
-const (
-	USE_MOCK_SERVER TestServerType = iota
-	USE_REAL_SERVER TestServerType = iota
-)
+// How the primary server fails, and how many requests the fallback receives.
+type FallbackTestCase struct {
+	name string
+	// The status code the primary responds with. Zero means the primary is
+	// unreachable.
+	statusCode    int
+	expectedCount int
+}
 
-func resetMockServer() {
-	url := url.URL{
-		Scheme: "http",
-		Host:   strings.Join([]string{MOCK_SERVER_HOST, MOCK_SERVER_PORT}, ":"),
-		Path:   "__mock__/reset",
-	}
-	r, err := http.NewRequest("POST", url.String(), nil)
-	if err != nil {
-		panic(err)
+func getFallbackEligibilityTestCases() []FallbackTestCase {
+	return []FallbackTestCase{
+		// NOTE: Fallback will be called after one 400 error because it does not
+		// retry.
+		{"404", 404, 0},
+		{"400", 400, 1},
+		{"500", 500, 1},
+		{"unreachable", 0, 1},
+		{"300", 300, 0},
 	}
+}
 
-	r.Header.Add("Content-Type", "application/json")
-	client := &http.Client{}
-	res, err := client.Do(r)
-	if err != nil {
-		panic(err)
+func getTestFacts() []oso.Fact {
+	return []oso.Fact{
+		oso.NewFact("has_permission", oso.NewValue("User", "bob"), oso.String("read"), oso.NewValue("Repo", "acme")),
+		oso.NewFact("has_permission", oso.NewValue("User", "alice"), oso.String("read"), oso.NewValue("Repo", "acme")),
 	}
-
-	defer res.Body.Close()
 }
 
-func assertCalled(method mock.ConditionValue, path string, count uint, t *testing.T) {
-	mockConfig := &mock.MockConfig{Url: strings.Join([]string{MOCK_SERVER_HOST, MOCK_SERVER_PORT}, ":")}
-	validationErrors, err := mock.Assert(mockConfig, &mock.AssertOptions{
-		Route: path,
-		Condition: &mock.Condition{
-			Type:  mock.ConditionType_MethodMatch,
-			Value: method,
-		},
+// Start a fallback server holding the test facts, and a primary server which
+// fails with the given status code (or is unreachable if it is zero). Returns
+// a client which talks to the primary and falls back to the fallback server.
+func getOsoTestClient(t *testing.T, statusCode int) (oso.OsoClientCtx, *osotest.Server) {
+	t.Helper()
+	fallback := osotest.NewServer()
+	t.Cleanup(fallback.Close)
+	fallback.Client.AddRule(func(facts osotest.Facts, actor oso.Value, action string, resource oso.Value) bool {
+		return facts.Has(oso.NewFact("has_permission", actor, oso.String(action), resource))
 	})
-	if err != nil {
-		t.Error(err)
+	for _, fact := range getTestFacts() {
+		fallback.Client.Insert(fact)
 	}
 
-	if count == 0 {
-		for _, ve := range validationErrors {
-			if ve.Code == mock.ValidationErrorCode_NoCall {
-				return
-			}
-		}
-		t.Error("expected endpoint not to be called")
+	primary := osotest.NewServer()
+	if statusCode == 0 {
+		primary.Close()
 	} else {
-		if len(validationErrors) > 0 {
-			t.Error(mock.ToReadableError(validationErrors))
-		}
-	}
-}
-
-type OsoTestClients struct {
-	valid       OsoClientImpl
-	unreachable OsoClientImpl
-	httpError   OsoClientImpl
-	http300     OsoClientImpl
-	http400     OsoClientImpl
-	http404     OsoClientImpl
-}
-
-// Get some test clients.
-//
-// Supports two cases.
-//
-// In one case we just want to test that the fallback URL is called, to test
-// that all endpoints that we expect to fall back actually do. In this case we
-// use the mock test server.
-//
-// In the other case, we want to use a real functional Oso test server, so
-// that we can check that the request actually succeeds when the fallback
-// service is running.
-func getOsoTestClients(serverType TestServerType) OsoTestClients {
-	const realTestServer = "http://localhost:8081"
-	const mockTestServer = "http://localhost:4000"
-	var testServer string
-	if serverType == USE_MOCK_SERVER {
-		testServer = mockTestServer
-	} else {
-		testServer = realTestServer
-	}
-	const testServer300 = "http://localhost:4000/return-300"
-	const testServer404 = "http://localhost:4000/return-404"
-	const testServer400 = "http://localhost:4000/return-400"
-	const testServer500 = "http://localhost:4000/return-500"
-	const testServerNonexistent = "http://localhost:6000"
-	const apiKey = "e_0123456789_12345_osotesttoken01xiIn"
-	return OsoTestClients{
-		valid:       NewClient(testServer, apiKey).(OsoClientImpl),
-		unreachable: NewClientWithFallbackUrl(testServerNonexistent, apiKey, testServer).(OsoClientImpl),
-		httpError:   NewClientWithFallbackUrl(testServer500, apiKey, testServer).(OsoClientImpl),
-		http300:     NewClientWithFallbackUrl(testServer300, apiKey, testServer).(OsoClientImpl),
-		http400:     NewClientWithFallbackUrl(testServer400, apiKey, testServer).(OsoClientImpl),
-		http404:     NewClientWithFallbackUrl(testServer404, apiKey, testServer).(OsoClientImpl),
+		t.Cleanup(primary.Close)
+		primary.InjectFault(osotest.Fault{StatusCode: statusCode})
+	}
+	client := oso.NewClientWithFallbackUrl(primary.URL, fallbackTestAPIKey, fallback.URL)
+	return client.(oso.OsoClientCtx), fallback
+}
+
+// Check that call falls back for exactly the failures which should fall back,
+// by counting the requests the fallback server receives on the given path.
+func testFallbackEligibility(t *testing.T, path string, call func(client oso.OsoClientCtx)) {
+	for _, tc := range getFallbackEligibilityTestCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			client, fallback := getOsoTestClient(t, tc.statusCode)
+			call(client)
+			if got := fallback.Requests(path); got != tc.expectedCount {
+				t.Errorf("fallback received %d requests to %s, want %d", got, path, tc.expectedCount)
+			}
+		})
 	}
 }
 
-type FallbackTestCase struct {
-	client         OsoClient
-	expected_count uint
+func user() oso.Value {
+	return oso.Value{Type: "User", ID: "bob"}
 }
 
-func getFallbackEligibilityTestCases() []FallbackTestCase {
-	testClients := getOsoTestClients(USE_MOCK_SERVER)
-	const apiKey = "e_0123456789_12345_osotesttoken01xiIn"
-	return []FallbackTestCase{
-		// NOTE: Fallback will be called after one 400 error because it does not
-		// retry.
-		{testClients.http404, 0},
-		{testClients.http400, 1},
-		{testClients.httpError, 1},
-		{testClients.unreachable, 1},
-		{testClients.http300, 0},
-	}
+func repo() oso.Value {
+	return oso.Value{Type: "Repo", ID: "acme"}
 }
 
-func user() Value {
-	return Value{Type: "User", ID: "bob"}
-}
-
-func repo() Value {
-	return Value{Type: "Repo", ID: "acme"}
+func Test_AuthorizeFallback(t *testing.T) {
+	testFallbackEligibility(t, "/authorize", func(client oso.OsoClientCtx) {
+		client.Authorize(user(), "read", repo())
+	})
 }
 
-func Test_AuthorizeFallback(t *testing.T) {
-	testCases := getFallbackEligibilityTestCases()
-	for _, tc := range testCases {
-		tc.client.Authorize(
-			user(), "read", repo(),
-		)
-		assertCalled("post", "api/authorize", tc.expected_count, t)
-		resetMockServer()
-	}
+func Test_AuthorizeResourcesFallback(t *testing.T) {
+	testFallbackEligibility(t, "/authorize_resources", func(client oso.OsoClientCtx) {
+		client.AuthorizeResources(user(), "read", []oso.Value{repo()}, nil)
+	})
 }
 
 func Test_ListFallback(t *testing.T) {
-	testCases := getFallbackEligibilityTestCases()
-	for _, tc := range testCases {
-		tc.client.List(user(), "read", "Repo", nil)
-		assertCalled("post", "api/list", tc.expected_count, t)
-		resetMockServer()
-	}
+	testFallbackEligibility(t, "/list", func(client oso.OsoClientCtx) {
+		client.List(user(), "read", "Repo", nil)
+	})
 }
 
 func Test_ActionsFallback(t *testing.T) {
-	testCases := getFallbackEligibilityTestCases()
-	for _, tc := range testCases {
-		tc.client.Actions(
-			user(), repo(),
-		)
-		assertCalled("post", "api/actions", tc.expected_count, t)
-		resetMockServer()
-	}
+	testFallbackEligibility(t, "/actions", func(client oso.OsoClientCtx) {
+		client.Actions(user(), repo())
+	})
 }
 
 func Test_BuildQueryFallback(t *testing.T) {
-	testCases := getFallbackEligibilityTestCases()
-	for _, tc := range testCases {
+	testFallbackEligibility(t, "/evaluate_query", func(client oso.OsoClientCtx) {
 		var mapping map[string][]string
-		repoVar := TypedVar("Repo")
-		action := TypedVar("String")
-		tc.client.BuildQuery(NewQueryFact(
-			"allow", user(), String("read"), repo(),
-		),
-		).Evaluate(&mapping, map[Variable]Variable{repoVar: action})
-		assertCalled("post", "api/evaluate_query", tc.expected_count, t)
-		resetMockServer()
-	}
+		repoVar := oso.TypedVar("Repo")
+		action := oso.TypedVar("String")
+		client.BuildQuery(oso.NewQueryFact(
+			"allow", user(), oso.String("read"), repo(),
+		)).Evaluate(&mapping, map[oso.Variable]oso.Variable{repoVar: action})
+	})
 }
 
 func Test_AuthorizeLocalFallback(t *testing.T) {
-	testCases := getFallbackEligibilityTestCases()
-	for _, tc := range testCases {
-		tc.client.AuthorizeLocal(
-			user(), "read", repo(),
-		)
-		assertCalled("post", "api/authorize_query", tc.expected_count, t)
-		resetMockServer()
-	}
+	testFallbackEligibility(t, "/authorize_query", func(client oso.OsoClientCtx) {
+		client.AuthorizeLocal(user(), "read", repo())
+	})
 }
 
 func Test_ListLocalFallback(t *testing.T) {
-	testCases := getFallbackEligibilityTestCases()
-	for _, tc := range testCases {
-		tc.client.ListLocal(user(), "read", "repo()", "id")
-		assertCalled("post", "api/list_query", tc.expected_count, t)
-		resetMockServer()
-	}
+	testFallbackEligibility(t, "/list_query", func(client oso.OsoClientCtx) {
+		client.ListLocal(user(), "read", "Repo", "id")
+	})
 }
 
 func Test_ActionsLocalFallback(t *testing.T) {
-	testCases := getFallbackEligibilityTestCases()
-	for _, tc := range testCases {
-		tc.client.ActionsLocal(
-			user(), repo(),
-		)
-		assertCalled("post", "api/actions_query", tc.expected_count, t)
-		resetMockServer()
-	}
+	testFallbackEligibility(t, "/actions_query", func(client oso.OsoClientCtx) {
+		client.ActionsLocal(user(), repo())
+	})
 }
 
 func Test_GetFallback(t *testing.T) {
-	testCases := getFallbackEligibilityTestCases()
-	for _, tc := range testCases {
-		tc.client.Get(NewFactPattern("has_role", user(), nil, repo()))
-		assertCalled("get", "api/facts", tc.expected_count, t)
-		resetMockServer()
-	}
+	testFallbackEligibility(t, "/facts", func(client oso.OsoClientCtx) {
+		client.Get(oso.NewFactPattern("has_role", user(), nil, repo()))
+	})
 }
 
 func Test_PolicyMetadataFallback(t *testing.T) {
-	testCases := getFallbackEligibilityTestCases()
-	for _, tc := range testCases {
-		tc.client.GetPolicyMetadata()
-		assertCalled("get", "api/policy_metadata", tc.expected_count, t)
-		resetMockServer()
-	}
-}
-
-func getTestFacts() []Fact {
-	return []Fact{
-		{
-			"has_permission",
-			[]Value{
-				{Type: "User", ID: "bob"},
-				String("read"),
-				{Type: "Repo", ID: "acme"},
-			},
-		},
-		{
-			"has_permission",
-			[]Value{
-				{Type: "User", ID: "alice"},
-				String("read"),
-				{Type: "Repo", ID: "acme"},
-			},
-		},
-	}
-}
-
-var initializeServer sync.Once
-
-func getRealServerClients(t *testing.T) OsoTestClients {
-	testClients := getOsoTestClients(USE_REAL_SERVER)
-	initializeServer.Do(func() {
-		testClients.valid.clearData()
-		err := testClients.valid.Batch(func(tx BatchTransaction) {
-			for _, fact := range getTestFacts() {
-				tx.Insert(fact)
-			}
-		})
-		if err != nil {
-			t.Fatal("Failed to do test server setup: ", err)
-		}
+	testFallbackEligibility(t, "/policy_metadata", func(client oso.OsoClientCtx) {
+		client.GetPolicyMetadata()
 	})
-	return testClients
 }
 
 func Test_BatchFails(t *testing.T) {
-	testClients := getRealServerClients(t)
-	err := testClients.unreachable.Batch(func(tx BatchTransaction) {
-		tx.Insert(Fact{
-			"has_permission",
-			[]Value{
-				{Type: "User", ID: "alice"},
-				String("read"),
-				{Type: "Repo", ID: "acme"},
-			},
-		})
+	client, fallback := getOsoTestClient(t, 0)
+	err := client.Batch(func(tx oso.BatchTransaction) {
+		tx.Insert(getTestFacts()[1])
 	})
 	if err == nil {
 		t.Fatal("Expected insert to fail")
 	}
-	assertCalled("post", "api/batch", 0, t)
-	resetMockServer()
-}
-
-func Test_AuthorizeIfOsoIsUnreachable(t *testing.T) {
-	testClients := getRealServerClients(t)
-	res, err := testClients.unreachable.Authorize(
-		Value{Type: "User", ID: "bob"},
-		"read",
-		Value{Type: "Repo", ID: "acme"},
-	)
-	if err != nil {
-		t.Fatal("Expected authorize to succeed: ", err)
-	}
-	if res != true {
-		t.Fatal("Expected authorize to be true")
+	if got := fallback.Requests("/batch"); got != 0 {
+		t.Errorf("fallback received %d requests to /batch, want 0", got)
 	}
 }
 
-func Test_GetIfOsoIsUnreachable(t *testing.T) {
-	testClients := getRealServerClients(t)
-	perms, err := testClients.unreachable.Get(
-		NewFactPattern("has_permission", nil, String("read"), nil),
-	)
-	if err != nil {
-		t.Fatal("Expected get to succeed")
-	}
-	if len(perms) != len(getTestFacts()) {
-		t.Fatal("Expected permissions length to equal length of all our facts")
-	}
+// The primary failures after which reads are served by the fallback.
+var fallbackFailures = []struct {
+	name       string
+	statusCode int
+}{
+	{"unreachable", 0},
+	{"500", 500},
+	{"400", 400},
 }
 
-func Test_AuthorizeIfOsoReturnsHttpError(t *testing.T) {
-	testClients := getRealServerClients(t)
-	res, err := testClients.httpError.Authorize(
-		Value{Type: "User", ID: "bob"},
-		"read",
-		Value{Type: "Repo", ID: "acme"},
-	)
-	if err != nil {
-		t.Fatal("Expected authorize to succeed: ", err)
-	}
-	if res != true {
-		t.Fatal("Expected authorize to be true")
-	}
-}
-
-func Test_GetIfOsoReturnsHttpError(t *testing.T) {
-	testClients := getRealServerClients(t)
-	perms, err := testClients.unreachable.Get(
-		NewFactPattern("has_permission", nil, String("read"), nil),
-	)
-	if err != nil {
-		t.Fatal("Expected get to succeed")
-	}
-	if len(perms) != len(getTestFacts()) {
-		t.Fatal("Expected permissions length to equal length of all our facts")
-	}
-}
-
-func Test_AuthorizeIfOsoReturnsHttp400(t *testing.T) {
-	testClients := getRealServerClients(t)
-	res, err := testClients.http400.Authorize(
-		Value{Type: "User", ID: "bob"},
-		"read",
-		Value{Type: "Repo", ID: "acme"},
-	)
-	if err != nil {
-		t.Fatal("Expected authorize to succeed: ", err)
-	}
-	if res != true {
-		t.Fatal("Expected authorize to be true")
+func Test_AuthorizeIfOsoFails(t *testing.T) {
+	for _, tc := range fallbackFailures {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := getOsoTestClient(t, tc.statusCode)
+			res, err := client.Authorize(user(), "read", repo())
+			if err != nil {
+				t.Fatal("Expected authorize to succeed: ", err)
+			}
+			if res != true {
+				t.Fatal("Expected authorize to be true")
+			}
+		})
 	}
 }
 
-func Test_GetIfOsoReturnsHttp400(t *testing.T) {
-	testClients := getRealServerClients(t)
-	perms, err := testClients.http400.Get(
-		NewFactPattern("has_permission", nil, String("read"), nil),
-	)
-	if err != nil {
-		t.Fatal("Expected get to succeed")
-	}
-	if len(perms) != len(getTestFacts()) {
-		t.Fatal("Expected permissions length to equal length of all our facts")
+func Test_GetIfOsoFails(t *testing.T) {
+	for _, tc := range fallbackFailures {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := getOsoTestClient(t, tc.statusCode)
+			perms, err := client.Get(
+				oso.NewFactPattern("has_permission", nil, oso.String("read"), nil),
+			)
+			if err != nil {
+				t.Fatal("Expected get to succeed: ", err)
+			}
+			if len(perms) != len(getTestFacts()) {
+				t.Fatal("Expected permissions length to equal length of all our facts")
+			}
+		})
 	}
 }
 
 func Test_FallbackEndToEnd(t *testing.T) {
-	oso := NewClientWithFallbackUrl("http://localhost:6000", "e_0123456789_12345_osotesttoken01xiIn", "http://localhost:8081")
+	client, _ := getOsoTestClient(t, 0)
 
-	user := Value{Type: "User", ID: fmt.Sprintf("%v", idCounter)}
-	idCounter++
-	acme := Value{Type: "Repo", ID: fmt.Sprintf("%v", idCounter)}
-	idCounter++
+	user := oso.NewValue("User", "carol")
+	acme := oso.NewValue("Repo", "anvil")
 
 	t.Run("tell", func(t *testing.T) {
-		e := oso.Insert(NewFact("has_permission", user, String("read"), acme))
+		e := client.Insert(oso.NewFact("has_permission", user, oso.String("read"), acme))
 		if e == nil {
 			t.Fatalf("Insert should fail because it is not supported by fallback")
 		}
 	})
 
 	t.Run("authorize", func(t *testing.T) {
-		result, e := oso.AuthorizeWithContext(user, "read", acme, []Fact{
+		result, e := client.AuthorizeWithContext(user, "read", acme, []oso.Fact{
 			{
 				Predicate: "has_permission",
-				Args:      []Value{user, String("read"), acme},
+				Args:      []oso.Value{user, oso.String("read"), acme},
 			},
 		})
 		if e != nil || result != true {
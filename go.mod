@@ -3,9 +3,10 @@ module github.com/osohq/go-oso-cloud/v2
 go 1.14
 
 require (
-	github.com/dhuan/mock v1.4.3 // indirect
 	github.com/google/uuid v1.6.0
 	github.com/hashicorp/go-retryablehttp v0.7.7
 	gorm.io/driver/postgres v1.5.7
 	gorm.io/gorm v1.25.9
 )
+
+require github.com/stretchr/testify v1.9.0 // indirect
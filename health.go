@@ -0,0 +1,172 @@
+package oso
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The health of the Oso Cloud endpoints a client uses, as reported by
+// [OsoClientImpl.Health].
+type HealthReport struct {
+	Primary EndpointHealth `json:"primary"`
+	// One entry per fallback node, in the order they were configured.
+	Fallbacks []EndpointHealth `json:"fallbacks,omitempty"`
+	// The state of the circuit breaker configured with [WithCircuitBreaker].
+	Circuit CircuitState `json:"-"`
+}
+
+// Reports whether authorization requests can currently be answered, by Oso
+// Cloud or by a fallback node.
+func (r *HealthReport) Ready() bool {
+	if r.Primary.Healthy() {
+		return true
+	}
+	for i := range r.Fallbacks {
+		if r.Fallbacks[i].Healthy() {
+			return true
+		}
+	}
+	return false
+}
+
+// The result of probing a single endpoint.
+type EndpointHealth struct {
+	URL string `json:"url"`
+	// Whether the endpoint responded at all.
+	Reachable bool `json:"reachable"`
+	// Whether the endpoint accepted the API key.
+	Authorized bool `json:"authorized"`
+	// The status code of the response, or 0 if there was none.
+	StatusCode int `json:"status_code"`
+	// How long the endpoint took to respond.
+	Latency time.Duration `json:"-"`
+	// The OsoOffset reported by the endpoint, which identifies the latest
+	// write it has applied. A fallback node whose offset lags behind Oso
+	// Cloud's is serving stale data.
+	Offset ConsistencyToken `json:"offset,omitempty"`
+	// Why the probe failed, if it did.
+	Err error `json:"-"`
+}
+
+// Reports whether the endpoint responded successfully.
+func (h *EndpointHealth) Healthy() bool {
+	return h.Reachable && h.Authorized && h.Err == nil
+}
+
+// Probes Oso Cloud and the fallback nodes (if any) concurrently, without
+// retries, by fetching the policy metadata. Use ctx to bound how long the
+// probes may take.
+func (c *OsoClientImpl) Health(ctx context.Context) *HealthReport {
+	report := &HealthReport{Circuit: c.CircuitState()}
+	var wg sync.WaitGroup
+	if c.fallbacks != nil {
+		urls := c.fallbacks.urls()
+		report.Fallbacks = make([]EndpointHealth, len(urls))
+		for i, url := range urls {
+			wg.Add(1)
+			go func(i int, url string) {
+				defer wg.Done()
+				report.Fallbacks[i] = c.probe(ctx, url)
+			}(i, url)
+		}
+	}
+	report.Primary = c.probe(ctx, c.url)
+	wg.Wait()
+	return report
+}
+
+func (c *OsoClientImpl) probe(ctx context.Context, baseUrl string) EndpointHealth {
+	health := EndpointHealth{URL: baseUrl}
+	req, err := c.buildRequest(ctx, baseUrl, RequestData{method: "GET", path: "/policy_metadata"})
+	if err != nil {
+		health.Err = err
+		return health
+	}
+	start := time.Now()
+	res, err := c.probeHttpClient.Do(req)
+	health.Latency = time.Since(start)
+	if err != nil {
+		health.Err = err
+		return health
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+
+	health.Reachable = true
+	health.StatusCode = res.StatusCode
+	health.Authorized = res.StatusCode != http.StatusUnauthorized && res.StatusCode != http.StatusForbidden
+	health.Offset = ConsistencyToken(res.Header.Get("OsoOffset"))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var apiErr apiError
+		json.Unmarshal(body, &apiErr)
+		health.Err = &APIError{
+			StatusCode: res.StatusCode,
+			Message:    apiErr.Message,
+			RequestID:  res.Header.Get("X-Request-ID"),
+			Endpoint:   "/policy_metadata",
+		}
+	}
+	return health
+}
+
+// Anything that can report its health, such as an [OsoClientCtx] or a wrapper
+// around one.
+type HealthChecker interface {
+	Health(ctx context.Context) *HealthReport
+}
+
+// Returns an [http.Handler] for readiness probes. It responds with 200 if the
+// client can currently answer authorization requests (see
+// [HealthReport.Ready]), and 503 otherwise, with the report as JSON:
+//
+//	http.Handle("/readyz", oso.HealthHandler(client, 2*time.Second))
+//
+// Each probe is bounded by timeout, if it's positive.
+func HealthHandler(client HealthChecker, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		report := client.Health(ctx)
+
+		type endpoint struct {
+			EndpointHealth
+			Healthy   bool    `json:"healthy"`
+			LatencyMs float64 `json:"latency_ms"`
+			Error     string  `json:"error,omitempty"`
+		}
+		toEndpoint := func(h *EndpointHealth) *endpoint {
+			e := &endpoint{EndpointHealth: *h, Healthy: h.Healthy(), LatencyMs: float64(h.Latency) / float64(time.Millisecond)}
+			if h.Err != nil {
+				e.Error = h.Err.Error()
+			}
+			return e
+		}
+		response := struct {
+			Ready     bool        `json:"ready"`
+			Circuit   string      `json:"circuit"`
+			Primary   *endpoint   `json:"primary"`
+			Fallbacks []*endpoint `json:"fallbacks,omitempty"`
+		}{
+			Ready:   report.Ready(),
+			Circuit: report.Circuit.String(),
+			Primary: toEndpoint(&report.Primary),
+		}
+		for i := range report.Fallbacks {
+			response.Fallbacks = append(response.Fallbacks, toEndpoint(&report.Fallbacks[i]))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !response.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+}
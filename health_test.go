@@ -0,0 +1,88 @@
+package oso
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(401)
+		w.Write([]byte(`{"message":"invalid API key"}`))
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/policy_metadata" {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("OsoOffset", "42")
+		w.Write([]byte(`{"metadata":{"resources":{}}}`))
+	}))
+
+	o, err := New(primary.URL, "e_0123456789_12345_osotesttoken01xiIn", WithFallbackUrl(fallback.URL))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	report := o.Health(context.Background())
+	if !report.Primary.Reachable || report.Primary.Authorized || report.Primary.StatusCode != 401 || report.Primary.Healthy() {
+		t.Fatalf("unexpected primary health: %+v", report.Primary)
+	}
+	if len(report.Fallbacks) != 1 || !report.Fallbacks[0].Healthy() || report.Fallbacks[0].Offset != "42" || report.Fallbacks[0].Latency <= 0 {
+		t.Fatalf("unexpected fallback health: %+v", report.Fallbacks)
+	}
+	if !report.Ready() {
+		t.Fatalf("report is not ready")
+	}
+
+	handler := HealthHandler(o, 0)
+	check := func(expectedStatus int, expectedReady bool) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+		var body struct {
+			Ready     bool `json:"ready"`
+			Fallbacks []struct {
+				Healthy bool `json:"healthy"`
+			} `json:"fallbacks"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid response %q: %v", recorder.Body.String(), err)
+		}
+		if recorder.Code != expectedStatus || body.Ready != expectedReady || len(body.Fallbacks) != 1 || body.Fallbacks[0].Healthy != expectedReady {
+			t.Fatalf("handler responded %d %s, want %d", recorder.Code, recorder.Body.String(), expectedStatus)
+		}
+	}
+	check(200, true)
+
+	fallback.Close()
+	report = o.Health(context.Background())
+	if report.Fallbacks[0].Reachable || report.Fallbacks[0].Err == nil {
+		t.Fatalf("unexpected fallback health: %+v", report.Fallbacks)
+	}
+	check(503, false)
+}
+
+type fakeHealthChecker struct {
+	report   HealthReport
+	deadline bool
+}
+
+func (f *fakeHealthChecker) Health(ctx context.Context) *HealthReport {
+	_, f.deadline = ctx.Deadline()
+	return &f.report
+}
+
+func TestHealthHandlerChecker(t *testing.T) {
+	checker := &fakeHealthChecker{report: HealthReport{Primary: EndpointHealth{Reachable: true}, Circuit: CircuitOpen}}
+	recorder := httptest.NewRecorder()
+	HealthHandler(checker, time.Second).ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	if recorder.Code != 503 || !checker.deadline {
+		t.Fatalf("handler responded %d %s with deadline %t, want 503 with a deadline", recorder.Code, recorder.Body.String(), checker.deadline)
+	}
+}
@@ -0,0 +1,67 @@
+// Package backend lets other implementations of the client in this module, ie.
+// the osotest fake, construct types of package oso that only it can create:
+// query builders that evaluate queries in memory, batch transactions that
+// record their operations, and iterators over facts in memory.
+//
+// Package oso sets the hooks when it is initialized. It can't be imported
+// from here, so its types are passed as interface{}; the comment on each hook
+// gives its real signature.
+package backend
+
+import "context"
+
+// The structure of a query built with an oso.QueryBuilder, as passed to a
+// [QueryFunc].
+//
+// Variables are referred to by name. Values in the query (as opposed to
+// variables) are represented as variables constrained to a single ID.
+type Query struct {
+	Predicate QueryCall
+	Calls     []QueryCall
+	Variables map[string]QueryVariable
+	// A []oso.Fact.
+	ContextFacts interface{}
+}
+
+// A predicate in a [Query], applied to the named variables.
+type QueryCall struct {
+	Predicate string
+	Args      []string
+}
+
+// The type of a [Query] variable, and the IDs it is constrained to. A nil IDs
+// slice means the variable is unconstrained.
+type QueryVariable struct {
+	Type string
+	IDs  []string
+}
+
+// A QueryFunc evaluates a [Query], returning one row per result. Each row maps
+// the name of every variable in the query to its value in that result.
+type QueryFunc func(ctx context.Context, query Query) ([]map[string]string, error)
+
+// A single insert or delete recorded by [RecordBatch]. Exactly one of Insert
+// (a *oso.Fact) and Delete (an oso.IntoFactPattern) is set.
+type Operation struct {
+	Insert interface{}
+	Delete interface{}
+}
+
+var (
+	// func(fact oso.QueryFact, evaluate QueryFunc) oso.QueryBuilder
+	//
+	// Creates a query builder whose Evaluate* methods call evaluate, and whose
+	// EvaluateLocal* methods return an error.
+	NewQueryBuilder func(fact interface{}, evaluate QueryFunc) interface{}
+
+	// func(fn func(oso.BatchTransaction)) ([]Operation, error)
+	//
+	// Runs fn against a batch transaction which records the inserts and
+	// deletes made, in order. Returns an error if any of them were invalid.
+	RecordBatch func(fn interface{}) ([]Operation, error)
+
+	// func(facts []oso.Fact) *oso.FactIterator
+	//
+	// Returns an iterator over the given facts.
+	NewFactIterator func(facts interface{}) interface{}
+)
@@ -0,0 +1,147 @@
+package oso
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// The default number of facts a [FactIterator] decodes at a time.
+const DefaultBufferSize = 1000
+
+// Options for [OsoClientImpl.GetIter].
+type GetIterOptions struct {
+	// The number of facts decoded from the response at a time, and so the most
+	// facts the iterator holds in memory. Oso Cloud sends every matching fact
+	// in a single response, which isn't paginated; this only bounds how much
+	// of it is decoded ahead of the caller. Defaults to [DefaultBufferSize].
+	BufferSize int
+}
+
+// FactIterator streams facts, decoding them from the response as they are
+// consumed rather than all at once. Use it like:
+//
+//	it, err := client.GetIter(pattern, nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		fact := it.Fact()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type FactIterator struct {
+	body       io.Closer
+	decoder    *json.Decoder
+	bufferSize int
+	started    bool
+	done       bool
+	buffered   []Fact
+	current    Fact
+	err        error
+}
+
+func newFactIterator(body io.ReadCloser, options *GetIterOptions) *FactIterator {
+	bufferSize := DefaultBufferSize
+	if options != nil && options.BufferSize > 0 {
+		bufferSize = options.BufferSize
+	}
+	return &FactIterator{
+		body:       body,
+		decoder:    json.NewDecoder(body),
+		bufferSize: bufferSize,
+	}
+}
+
+// Advances to the next fact, which is then available through
+// [FactIterator.Fact]. Returns false when there are no more facts or an error
+// occurred; check [FactIterator.Err] to tell them apart.
+func (it *FactIterator) Next() bool {
+	if len(it.buffered) == 0 && !it.done {
+		it.fill()
+	}
+	if len(it.buffered) == 0 {
+		return false
+	}
+	it.current = it.buffered[0]
+	it.buffered = it.buffered[1:]
+	return true
+}
+
+// Returns the current fact.
+func (it *FactIterator) Fact() Fact {
+	return it.current
+}
+
+// Returns the error that stopped the iteration, if any.
+func (it *FactIterator) Err() error {
+	return it.err
+}
+
+// Releases the underlying response. It is safe to call Close more than once,
+// and it is called automatically when the iterator is exhausted.
+func (it *FactIterator) Close() error {
+	it.buffered = nil
+	return it.release()
+}
+
+// Closes the response once no more facts will be decoded from it. Facts that
+// were already decoded can still be consumed.
+func (it *FactIterator) release() error {
+	it.done = true
+	if it.body == nil {
+		return nil
+	}
+	body := it.body
+	it.body = nil
+	return body.Close()
+}
+
+// Decodes the next batch of facts from the response.
+func (it *FactIterator) fill() {
+	if !it.started {
+		it.started = true
+		token, err := it.decoder.Token()
+		if err != nil {
+			it.fail(err)
+			return
+		}
+		if token == nil {
+			// An empty result may be sent as null.
+			it.release()
+			return
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			it.fail(fmt.Errorf("unexpected token in facts response: %v", token))
+			return
+		}
+	}
+
+	it.buffered = make([]Fact, 0, it.bufferSize)
+	for len(it.buffered) < it.bufferSize {
+		if !it.decoder.More() {
+			// Consume the closing bracket.
+			if _, err := it.decoder.Token(); err != nil {
+				it.fail(err)
+				return
+			}
+			it.release()
+			return
+		}
+		var f fact
+		if err := it.decoder.Decode(&f); err != nil {
+			it.fail(err)
+			return
+		}
+		external, _ := fromInternalFact(f)
+		it.buffered = append(it.buffered, *external)
+	}
+}
+
+func (it *FactIterator) fail(err error) {
+	it.err = err
+	it.release()
+}
@@ -0,0 +1,158 @@
+package oso
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case levelDebug:
+		return "DEBUG"
+	case levelInfo:
+		return "INFO"
+	case levelWarn:
+		return "WARN"
+	}
+	return "ERROR"
+}
+
+const redacted = "[REDACTED]"
+
+// How many API keys a clientLogger remembers to redact, so that keys which
+// were rotated out recently are still redacted.
+const maxRedactedSecrets = 4
+
+// Wraps the logger given with [WithLogger] or [WithSlogLogger], for the
+// client's own events and those of the retrying HTTP client. It redacts the
+// API keys the client has sent from everything it logs. A nil clientLogger
+// logs nothing.
+type clientLogger struct {
+	logger  interface{}
+	mu      sync.Mutex
+	secrets []string
+}
+
+var _ retryablehttp.LeveledLogger = (*clientLogger)(nil)
+
+// Returns nil if logger is nil or isn't a supported logger.
+func newClientLogger(logger interface{}, apiKey string) *clientLogger {
+	switch logger.(type) {
+	case retryablehttp.LeveledLogger, retryablehttp.Logger:
+		l := &clientLogger{logger: logger}
+		l.addSecret(apiKey)
+		return l
+	}
+	return nil
+}
+
+// Makes sure the given secret is redacted from what's logged from now on.
+func (l *clientLogger) addSecret(secret string) {
+	if l == nil || secret == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, s := range l.secrets {
+		if s == secret {
+			return
+		}
+	}
+	l.secrets = append(l.secrets, secret)
+	if len(l.secrets) > maxRedactedSecrets {
+		l.secrets = l.secrets[1:]
+	}
+}
+
+func (l *clientLogger) log(level logLevel, msg string, keysAndValues ...interface{}) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	secrets := l.secrets
+	l.mu.Unlock()
+	msg = redact(msg, secrets)
+	redactedKeysAndValues := make([]interface{}, len(keysAndValues))
+	for i, v := range keysAndValues {
+		redactedKeysAndValues[i] = redactValue(v, secrets)
+	}
+
+	switch logger := l.logger.(type) {
+	case retryablehttp.LeveledLogger:
+		switch level {
+		case levelDebug:
+			logger.Debug(msg, redactedKeysAndValues...)
+		case levelInfo:
+			logger.Info(msg, redactedKeysAndValues...)
+		case levelWarn:
+			logger.Warn(msg, redactedKeysAndValues...)
+		default:
+			logger.Error(msg, redactedKeysAndValues...)
+		}
+	case retryablehttp.Logger:
+		var b strings.Builder
+		fmt.Fprintf(&b, "[%s] %s", level, msg)
+		for i := 0; i+1 < len(redactedKeysAndValues); i += 2 {
+			fmt.Fprintf(&b, " %v=%v", redactedKeysAndValues[i], redactedKeysAndValues[i+1])
+		}
+		logger.Printf("%s", b.String())
+	}
+}
+
+func redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// Redacts strings, and values which render as strings, that contain a secret.
+// Other values are logged as they are.
+func redactValue(v interface{}, secrets []string) interface{} {
+	if len(secrets) == 0 {
+		return v
+	}
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		return v
+	}
+	if r := redact(s, secrets); r != s {
+		return r
+	}
+	return v
+}
+
+func (l *clientLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(levelDebug, msg, keysAndValues...)
+}
+
+func (l *clientLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(levelInfo, msg, keysAndValues...)
+}
+
+func (l *clientLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log(levelWarn, msg, keysAndValues...)
+}
+
+func (l *clientLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.log(levelError, msg, keysAndValues...)
+}
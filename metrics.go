@@ -0,0 +1,113 @@
+package oso
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// A MetricsRecorder receives measurements of the calls a client makes to Oso
+// Cloud. Implementations must be safe for concurrent use, and should return
+// quickly, as they are called inline. The osoprom package provides a
+// Prometheus implementation.
+type MetricsRecorder interface {
+	// Called once for every API call, after its response has been read.
+	ObserveRequest(RequestMetrics)
+	// Called once for every successful Authorize call, and for every decision
+	// made by the client's ErrorPolicy.
+	ObserveDecision(DecisionMetrics)
+}
+
+// Measurements of a single API call. A call may be made of several HTTP
+// requests: retries, and a final request to the fallback URL.
+type RequestMetrics struct {
+	// The API endpoint, eg. "/authorize".
+	Endpoint string
+	// The HTTP method, eg. "POST".
+	Method string
+	// The status code of the final response, or 0 if there was none.
+	StatusCode int
+	// The time from the first request until the final response was read.
+	Duration time.Duration
+	// The number of retries after the initial request.
+	Retries int
+	// Whether the call was sent to the fallback URL.
+	Fallback bool
+	// The size of the request and response bodies in bytes.
+	RequestBytes  int
+	ResponseBytes int
+	// The error the call failed with, if any.
+	Err error
+}
+
+// The outcome of an authorization decision.
+type DecisionMetrics struct {
+	Action  string
+	Allowed bool
+	// Whether the decision was made by the client's [ErrorPolicy], because
+	// Oso Cloud couldn't answer.
+	Degraded bool
+}
+
+// Report measurements of every call to the given recorder.
+func WithMetricsRecorder(recorder MetricsRecorder) Option {
+	return func(o *clientOptions) {
+		o.metrics = recorder
+	}
+}
+
+func (c *OsoClientImpl) observeRequest(ctx context.Context, requestData RequestData, err error) {
+	info := callInfoFromContext(ctx)
+	statusCode := info.statusCode
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		statusCode = apiErr.StatusCode
+	}
+	c.metrics.ObserveRequest(RequestMetrics{
+		Endpoint:      requestData.path,
+		Method:        requestData.method,
+		StatusCode:    statusCode,
+		Duration:      time.Since(info.start),
+		Retries:       info.retries,
+		Fallback:      info.fallback,
+		RequestBytes:  info.requestBytes,
+		ResponseBytes: info.responseBytes,
+		Err:           err,
+	})
+}
+
+func (c *OsoClientImpl) observeDecision(action string, allowed bool) {
+	if c.metrics != nil {
+		c.metrics.ObserveDecision(DecisionMetrics{Action: action, Allowed: allowed})
+	}
+}
+
+func (c *OsoClientImpl) observeDegradedDecision(action string, allowed bool) {
+	if c.metrics != nil {
+		c.metrics.ObserveDecision(DecisionMetrics{Action: action, Allowed: allowed, Degraded: true})
+	}
+}
+
+// A response body which counts the bytes read from it, and reports the count
+// once when it is closed.
+type observedBody struct {
+	io.ReadCloser
+	n        int
+	observe  func(n int)
+	observed bool
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += n
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	if !b.observed {
+		b.observed = true
+		b.observe(b.n)
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,120 @@
+package oso
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type fakeRecorder struct {
+	mu        sync.Mutex
+	requests  []RequestMetrics
+	decisions []DecisionMetrics
+}
+
+func (r *fakeRecorder) ObserveRequest(m RequestMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, m)
+}
+
+func (r *fakeRecorder) ObserveDecision(m DecisionMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decisions = append(r.decisions, m)
+}
+
+func TestMetricsRecorder(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/authorize":
+			w.Write([]byte(`{"allowed":false}`))
+		case "/api/actions":
+			w.WriteHeader(503)
+		default:
+			w.WriteHeader(404)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results":["read"]}`))
+	}))
+	defer fallback.Close()
+
+	recorder := &fakeRecorder{}
+	o, err := New(primary.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		WithFallbackUrl(fallback.URL),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 0}),
+		WithMetricsRecorder(recorder))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+
+	if allowed, err := o.Authorize(alice, "write", acme); err != nil || allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, false)
+	}
+	if _, err := o.Actions(alice, acme); err != nil {
+		t.Fatalf("Actions failed: %v", err)
+	}
+	if _, err := o.Get(NewFactPattern("has_role", nil, nil, nil)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get error = %v, want ErrNotFound", err)
+	}
+
+	if len(recorder.requests) != 3 {
+		t.Fatalf("observed %d requests, want 3", len(recorder.requests))
+	}
+	authorize, actions, get := recorder.requests[0], recorder.requests[1], recorder.requests[2]
+	if authorize.Endpoint != "/authorize" || authorize.Method != "POST" || authorize.StatusCode != 200 ||
+		authorize.Fallback || authorize.Err != nil || authorize.RequestBytes == 0 ||
+		authorize.ResponseBytes != len(`{"allowed":false}`) || authorize.Duration <= 0 {
+		t.Fatalf("unexpected authorize metrics: %+v", authorize)
+	}
+	if actions.Endpoint != "/actions" || actions.StatusCode != 200 || !actions.Fallback {
+		t.Fatalf("unexpected actions metrics: %+v", actions)
+	}
+	if get.Endpoint != "/facts" || get.Method != "GET" || get.StatusCode != 404 || !errors.Is(get.Err, ErrNotFound) || get.RequestBytes != 0 {
+		t.Fatalf("unexpected get metrics: %+v", get)
+	}
+
+	expected := []DecisionMetrics{{Action: "write", Allowed: false}}
+	if len(recorder.decisions) != 1 || recorder.decisions[0] != expected[0] {
+		t.Fatalf("observed decisions %+v, want %+v", recorder.decisions, expected)
+	}
+}
+
+func TestMetricsBulkDecisions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results":[{"type":"Repo","id":"acme"}]}`))
+	}))
+	defer server.Close()
+
+	recorder := &fakeRecorder{}
+	o, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", WithMetricsRecorder(recorder))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	resources := []Value{NewValue("Repo", "acme"), NewValue("Repo", "anvil"), NewValue("Repo", "secret")}
+	if _, err := o.AuthorizeResources(NewValue("User", "alice"), "read", resources, nil); err != nil {
+		t.Fatalf("AuthorizeResources failed: %v", err)
+	}
+
+	allowed, denied := 0, 0
+	for _, decision := range recorder.decisions {
+		if decision.Action != "read" || decision.Degraded {
+			t.Fatalf("unexpected decision metrics: %+v", decision)
+		}
+		if decision.Allowed {
+			allowed++
+		} else {
+			denied++
+		}
+	}
+	if allowed != 1 || denied != 2 {
+		t.Fatalf("observed %d allowed and %d denied decisions, want 1 and 2", allowed, denied)
+	}
+}
@@ -0,0 +1,166 @@
+package oso
+
+import (
+	"net/http"
+	"time"
+)
+
+// An Option configures the client returned by [New].
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	fallbackUrls      []string
+	fallbackSelection FallbackSelection
+	logger            interface{}
+	dataBindings      string
+	httpClient        *http.Client
+	transport         http.RoundTripper
+	retryPolicy       RetryPolicy
+	timeout           time.Duration
+	fallbackTimeout   time.Duration
+	userAgentSuffix   string
+	clientId          string
+	decisionCache     *DecisionCacheConfig
+	tracer            Tracer
+	tracedTypes       bool
+	metrics           MetricsRecorder
+	circuitBreaker    *CircuitBreakerConfig
+	errorPolicy       ErrorPolicy
+	credentials       CredentialsProvider
+}
+
+func defaultClientOptions() clientOptions {
+	return clientOptions{
+		retryPolicy: DefaultRetryPolicy(),
+	}
+}
+
+// RetryPolicy controls how requests to Oso Cloud are retried when they fail
+// with a connection error or a retryable status code (429, or 5xx other than
+// 501). Waits between attempts grow exponentially from WaitMin to WaitMax,
+// except after a 429 or 503 with a Retry-After header, which is honoured. If
+// Retry-After asks for longer than WaitMax, or than is left before the
+// context's deadline, the request isn't retried and fails with an [*APIError]
+// for that response, eg. one matching [ErrRateLimited].
+type RetryPolicy struct {
+	// The maximum number of retries after the initial attempt.
+	MaxRetries int
+	// The minimum time to wait between attempts.
+	WaitMin time.Duration
+	// The maximum time to wait between attempts. A request whose Retry-After
+	// header asks for a longer wait isn't retried.
+	WaitMax time.Duration
+	// Randomly shorten each wait by up to this fraction of it, so that
+	// clients retrying at the same time spread out. Must be between 0 and 1;
+	// [New] returns an error otherwise.
+	Jitter float64
+	// Overrides MaxRetries for individual API paths, eg. "/authorize".
+	//
+	// Regardless of this setting, "/batch" is only retried when the call has
+	// an idempotency key (see [WithIdempotencyKey]), since retrying a write
+	// that Oso Cloud did apply would apply it twice.
+	EndpointMaxRetries map[string]int
+	// Limits retries across all calls made by the client, so that an outage
+	// doesn't multiply the load on Oso Cloud. Nil means no limit.
+	Budget *RetryBudget
+}
+
+// A RetryBudget allows Ratio retries per call, on average, plus bursts of up
+// to Burst retries. For example, a Ratio of 0.1 allows one retry for every ten
+// calls.
+type RetryBudget struct {
+	Ratio float64
+	Burst int
+}
+
+// The retry policy used unless [WithRetryPolicy] is given.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		WaitMin:    10 * time.Millisecond,
+		WaitMax:    1 * time.Second,
+	}
+}
+
+// Send eligible requests to the given fallback URL if Oso Cloud is unavailable.
+// See https://www.osohq.com/docs/guides/production/deploy-fallback-node
+func WithFallbackUrl(fallbackUrl string) Option {
+	return WithFallbackUrls(fallbackUrl)
+}
+
+// Use a custom logger for the retrying HTTP client, and for the client's own
+// events (see [WithSlogLogger]). The API key is redacted from everything the
+// client logs.
+//
+// See https://pkg.go.dev/github.com/hashicorp/go-retryablehttp@v0.7.1#LeveledLogger
+// for documentation on the logger interfaces supported.
+func WithLogger(logger interface{}) Option {
+	return func(o *clientOptions) {
+		o.logger = logger
+	}
+}
+
+// Read data bindings for the *Local methods from the file at the given path.
+func WithDataBindings(path string) Option {
+	return func(o *clientOptions) {
+		o.dataBindings = path
+	}
+}
+
+// Send requests to Oso Cloud through the given HTTP client. Retries are still
+// handled by the Oso client, so the given client should not retry by itself.
+// The client is copied, so later changes to it have no effect.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *clientOptions) {
+		o.httpClient = client
+	}
+}
+
+// Send requests to Oso Cloud and the fallback URL through the given transport.
+// Takes precedence over the transport of a client passed to [WithHTTPClient].
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *clientOptions) {
+		o.transport = transport
+	}
+}
+
+// Retry failed requests to Oso Cloud according to the given policy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *clientOptions) {
+		o.retryPolicy = policy
+	}
+}
+
+// Give up on a request to Oso Cloud, including all of its retries, after the
+// given duration. Zero means no timeout. For streamed reads (see
+// [OsoClientImpl.GetIter]), the timeout only applies until the response
+// headers arrive; bound the rest with the context instead.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// Give up on a request to the fallback URL after the given duration. Zero means
+// no timeout.
+func WithFallbackTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.fallbackTimeout = timeout
+	}
+}
+
+// Append the given string to the User-Agent header sent to Oso Cloud, eg. to
+// identify the calling service.
+func WithUserAgentSuffix(suffix string) Option {
+	return func(o *clientOptions) {
+		o.userAgentSuffix = suffix
+	}
+}
+
+// Identify this client to Oso Cloud with the given ID instead of a random one.
+// The ID is sent in the X-Oso-Instance-Id header.
+func WithClientId(clientId string) Option {
+	return func(o *clientOptions) {
+		o.clientId = clientId
+	}
+}
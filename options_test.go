@@ -0,0 +1,91 @@
+package oso
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingTransport struct {
+	calls int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.calls, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewWithOptions(t *testing.T) {
+	var userAgent, instanceId string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		instanceId = r.Header.Get("X-Oso-Instance-Id")
+		w.Write([]byte(`{"allowed": true}`))
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	o, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		WithTransport(transport),
+		WithUserAgentSuffix("billing-service/1.2"),
+		WithClientId("billing-1"),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	allowed, err := o.Authorize(NewValue("User", "alice"), "read", NewValue("Repo", "acme"))
+	if err != nil || !allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+	}
+	if !strings.HasSuffix(userAgent, " billing-service/1.2") {
+		t.Fatalf("User-Agent = %q, want suffix %q", userAgent, "billing-service/1.2")
+	}
+	if instanceId != "billing-1" {
+		t.Fatalf("X-Oso-Instance-Id = %q, want %q", instanceId, "billing-1")
+	}
+	if n := atomic.LoadInt32(&transport.calls); n != 1 {
+		t.Fatalf("transport called %d times, want 1", n)
+	}
+}
+
+func TestNewWithTimeoutAndRetryPolicy(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(500)
+			w.Write([]byte(`{"message": "try again"}`))
+			return
+		}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	o, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		WithRetryPolicy(RetryPolicy{MaxRetries: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond}),
+		WithTimeout(100*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	_, err = o.Authorize(NewValue("User", "alice"), "read", NewValue("Repo", "acme"))
+	if err == nil {
+		t.Fatalf("Authorize succeeded, want timeout error")
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("server called %d times, want 2", n)
+	}
+}
+
+func TestNewWithMissingDataBindings(t *testing.T) {
+	_, err := New("http://localhost:8081", "e_0123456789_12345_osotesttoken01xiIn", WithDataBindings("does-not-exist.yaml"))
+	if err == nil {
+		t.Fatalf("New succeeded with a missing data bindings file")
+	}
+}
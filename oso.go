@@ -3,16 +3,20 @@
 package oso
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/osohq/go-oso-cloud/v2/internal/backend"
 )
 
 // A Value is an argument to a [Fact]. Example:
@@ -118,6 +122,12 @@ type AuthorizeResult authorizeResult
 type AuthorizeOptions struct {
 	ContextFacts []Fact
 	ParityHandle *ParityHandle
+	// Bounds how long the check may take, including retries. The check fails
+	// with context.DeadlineExceeded once it passes, which the client's
+	// [ErrorPolicy] may turn into a degraded decision.
+	Timeout time.Duration
+	// If set, filled in with how the decision was reached.
+	Outcome *AuthorizeOutcome
 }
 
 // Constructs a [Value] from the given string.
@@ -145,10 +155,10 @@ func fromValue(value concreteValue) (*Value, error) {
 
 func toConcreteValue(instance Value) (*concreteValue, error) {
 	if instance.Type == "" {
-		return nil, errors.New("Value must have a non-empty Type")
+		return nil, invalidValueError("Value must have a non-empty Type")
 	}
 	if instance.ID == "" {
-		return nil, errors.New("Value must have a non-empty ID")
+		return nil, invalidValueError("Value must have a non-empty ID")
 	}
 	return &concreteValue{Id: instance.ID, Type: instance.Type}, nil
 }
@@ -176,13 +186,16 @@ func fromInternalFact(f fact) (*Fact, error) {
 	return &Fact{Predicate: f.Predicate, Args: instanceArgs}, nil
 }
 
-func mapToInternalFacts(facts []Fact) []fact {
+func mapToInternalFacts(facts []Fact) ([]fact, error) {
 	payload := []fact{}
 	for _, f := range facts {
-		internalFact, _ := toInternalFact(f)
+		internalFact, err := toInternalFact(f)
+		if err != nil {
+			return nil, err
+		}
 		payload = append(payload, *internalFact)
 	}
-	return payload
+	return payload, nil
 }
 
 func mapFromInternalFacts(facts []fact) []Fact {
@@ -228,7 +241,7 @@ func toVariableValue(v ValuePattern) (*variableValue, error) {
 	var id *string
 	if value, isValue := v.(Value); isValue {
 		if value.Type == "" || value.ID == "" {
-			return nil, errors.New("Value must have non-empty Type and ID")
+			return nil, invalidValueError("Value must have non-empty Type and ID")
 		}
 		typ = value.Type
 		id = &value.ID
@@ -236,7 +249,7 @@ func toVariableValue(v ValuePattern) (*variableValue, error) {
 
 	if valueOfType, isValueOfType := v.(ValueOfType); isValueOfType {
 		if valueOfType.Type == "" {
-			return nil, errors.New("ValueOfType must have non-empty Type")
+			return nil, invalidValueError("ValueOfType must have non-empty Type")
 		}
 		typ = valueOfType.Type
 	}
@@ -304,8 +317,48 @@ func (tx *batchTransaction) Delete(data IntoFactPattern) error {
 
 func (tx batchTransaction) privateMarker() {}
 
+// A batch transaction which records the inserts and deletes made, without
+// sending anything to Oso Cloud; see [backend.RecordBatch].
+type batchRecorder struct {
+	operations []backend.Operation
+	batcherror error
+}
+
+func (tx *batchRecorder) Insert(data Fact) error {
+	if _, err := toInternalFact(data); err != nil {
+		tx.batcherror = err
+		return err
+	}
+	tx.operations = append(tx.operations, backend.Operation{Insert: &data})
+	return nil
+}
+
+func (tx *batchRecorder) Delete(data IntoFactPattern) error {
+	if _, err := data.intoFactPattern(); err != nil {
+		tx.batcherror = err
+		return err
+	}
+	tx.operations = append(tx.operations, backend.Operation{Delete: data})
+	return nil
+}
+
+func (tx batchRecorder) privateMarker() {}
+
+func recordBatch(fn func(BatchTransaction)) ([]backend.Operation, error) {
+	tx := batchRecorder{}
+	fn(&tx)
+	if tx.batcherror != nil {
+		return nil, tx.batcherror
+	}
+	return tx.operations, nil
+}
+
 // An interface to make it possible to swap out Oso Cloud implementations (eg. for unit tests).
-// For more information on these functions, see [OsoClientImpl].
+// For more information on these functions, see [OsoClientImpl]. The osotest
+// package provides an in-memory implementation.
+//
+// Its method set doesn't change, so that other implementations keep compiling;
+// newer methods are on [OsoClientCtx].
 type OsoClient interface {
 	Insert(fact Fact) error
 	Delete(factOrFactPattern IntoFactPattern) error
@@ -332,30 +385,125 @@ type OsoClient interface {
 	ActionsLocalWithContext(actor Value, resource Value, contextFacts []Fact) (string, error)
 }
 
+// An [OsoClient] with context-aware variants of its methods, and the methods
+// added since OsoClient was fixed: bulk authorization, streaming reads,
+// chunked batches, consistency tokens, health checks and parameterized local
+// queries. [OsoClientImpl] and the osotest client implement it.
+//
+// Methods with a Ctx suffix take a [context.Context] which is attached to every
+// HTTP request they make, so cancellation and deadlines are honoured across
+// retries and the fallback call. (The WithContext suffix refers to context facts.)
+type OsoClientCtx interface {
+	OsoClient
+
+	BatchChunked(func(tx BatchTransaction)) (BatchResult, error)
+	GetIter(factOrFactPattern IntoFactPattern, options *GetIterOptions) (*FactIterator, error)
+	AuthorizeResources(actor Value, action string, resources []Value, options *AuthorizeOptions) ([]Value, error)
+	AuthorizeResourcesMap(actor Value, action string, resources []Value, options *AuthorizeOptions) (map[Value]bool, error)
+
+	InsertCtx(ctx context.Context, fact Fact) error
+	DeleteCtx(ctx context.Context, factOrFactPattern IntoFactPattern) error
+	BatchCtx(ctx context.Context, fn func(tx BatchTransaction)) error
+	BatchChunkedCtx(ctx context.Context, fn func(tx BatchTransaction)) (BatchResult, error)
+	InsertWithToken(ctx context.Context, fact Fact) (ConsistencyToken, error)
+	DeleteWithToken(ctx context.Context, factOrFactPattern IntoFactPattern) (ConsistencyToken, error)
+	BatchWithToken(ctx context.Context, fn func(tx BatchTransaction)) (ConsistencyToken, error)
+	ConsistencyToken() ConsistencyToken
+	Health(ctx context.Context) *HealthReport
+	GetCtx(ctx context.Context, factOrFactPattern IntoFactPattern) ([]Fact, error)
+	GetIterCtx(ctx context.Context, factOrFactPattern IntoFactPattern, options *GetIterOptions) (*FactIterator, error)
+
+	PolicyCtx(ctx context.Context, policy string) error
+	GetPolicyMetadataCtx(ctx context.Context) (*PolicyMetadata, error)
+
+	ActionsCtx(ctx context.Context, actor Value, resource Value, contextFacts []Fact) ([]string, error)
+	AuthorizeCtx(ctx context.Context, actor Value, action string, resource Value, options *AuthorizeOptions) (bool, error)
+	AuthorizeResourcesCtx(ctx context.Context, actor Value, action string, resources []Value, options *AuthorizeOptions) ([]Value, error)
+	AuthorizeResourcesMapCtx(ctx context.Context, actor Value, action string, resources []Value, options *AuthorizeOptions) (map[Value]bool, error)
+	ListCtx(ctx context.Context, actor Value, action string, resource string, contextFacts []Fact) ([]string, error)
+	AuthorizeLocalCtx(ctx context.Context, actor Value, action string, resource Value, options *AuthorizeOptions) (string, error)
+	ListLocalCtx(ctx context.Context, actor Value, action string, resource string, column string, contextFacts []Fact) (string, error)
+	ActionsLocalCtx(ctx context.Context, actor Value, resource Value, contextFacts []Fact) (string, error)
+	AuthorizeLocalParamsCtx(ctx context.Context, actor Value, action string, resource Value, options *AuthorizeOptions, placeholder Placeholder) (string, []interface{}, error)
+	ListLocalParamsCtx(ctx context.Context, actor Value, action string, resource string, column string, contextFacts []Fact, placeholder Placeholder, offset int) (string, []interface{}, error)
+	ActionsLocalParamsCtx(ctx context.Context, actor Value, resource Value, contextFacts []Fact, placeholder Placeholder) (string, []interface{}, error)
+}
+
 // The default implementation of [OsoClient]. Create an instance using the constructor
 // functions on [OsoClient].
+//
+// A client is safe for concurrent use by multiple goroutines, and should be
+// shared: its consistency token, decision cache, circuit breaker and retry
+// budget are shared by all calls made with it.
 type OsoClientImpl struct {
 	url                string
-	apiKey             string
+	credentials        CredentialsProvider
 	httpClient         *http.Client
 	userAgent          string
-	lastOffset         string
-	fallbackUrl        string
+	offset             *offsetTracker
+	fallbacks          *fallbackPool
 	fallbackHttpClient *http.Client
 	dataBindings       string
 	clientId           string
+	cache              *decisionCache
+	tracer             *tracer
+	metrics            MetricsRecorder
+	retrier            *retrier
+	breaker            *circuitBreaker
+	probeHttpClient    *http.Client
+	errorPolicy        ErrorPolicy
+	logger             *clientLogger
+	// Serializes refreshes of credentials after a 401.
+	refreshMu sync.Mutex
 }
 
-// Create a new Oso client with a fallbackURL and custom logger
+// Create a new Oso client configured with the given options.
 //
-// See https://pkg.go.dev/github.com/hashicorp/go-retryablehttp@v0.7.1#LeveledLogger
-// for documentation on the logger interfaces supported.
-func NewClientWithFallbackUrlAndLoggerAndDataBindings(url string, apiKey string, fallbackUrl string, logger interface{}, dataBindings string) OsoClient {
+//	oso, err := New("https://cloud.osohq.com", apiKey,
+//		WithFallbackUrl("http://localhost:8080"),
+//		WithTimeout(5*time.Second),
+//	)
+//
+// Returns an error if an option is invalid, eg. if the data bindings file
+// can't be read. The client is returned as its concrete type, so that methods
+// like [OsoClientImpl.DecisionCacheStats] and [OsoClientImpl.CircuitState]
+// are available without a type assertion.
+func New(url string, apiKey string, opts ...Option) (*OsoClientImpl, error) {
+	o := defaultClientOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.retryPolicy.Jitter < 0 || o.retryPolicy.Jitter > 1 {
+		return nil, fmt.Errorf("oso: RetryPolicy.Jitter must be between 0 and 1, not %v", o.retryPolicy.Jitter)
+	}
 	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = 3
-	retryClient.RetryWaitMin = 10 * time.Millisecond
-	retryClient.RetryWaitMax = 1 * time.Second
-	retryClient.Logger = logger
+	retrier := newRetrier(o.retryPolicy)
+	retryClient.RetryMax = retrier.maxRetries()
+	retryClient.RetryWaitMin = o.retryPolicy.WaitMin
+	retryClient.RetryWaitMax = o.retryPolicy.WaitMax
+	retryClient.CheckRetry = retrier.checkRetry
+	retryClient.Backoff = retrier.backoff
+	logger := newClientLogger(o.logger, apiKey)
+	if logger != nil {
+		retryClient.Logger = logger
+	} else {
+		retryClient.Logger = o.logger
+	}
+	// Hand the last response back once retries are exhausted so that it can be
+	// reported as an *APIError instead of a generic "giving up" error.
+	retryClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
+	retryClient.RequestLogHook = logRetries(logger)
+	if o.httpClient != nil {
+		// Copy the client so that our changes don't leak back to the caller.
+		httpClient := *o.httpClient
+		retryClient.HTTPClient = &httpClient
+	}
+	if o.transport != nil {
+		retryClient.HTTPClient.Transport = o.transport
+	}
+	httpClient := retryClient.StandardClient()
+	httpClient.Timeout = o.timeout
 
 	var userAgent string
 	rv, err := os.ReadFile("VERSION")
@@ -364,38 +512,96 @@ func NewClientWithFallbackUrlAndLoggerAndDataBindings(url string, apiKey string,
 	} else {
 		userAgent = "Oso Cloud (golang " + runtime.Version() + "; rv:" + strings.TrimSuffix(string(rv), "\n") + ")"
 	}
-
-	lastOffset := ""
+	if o.userAgentSuffix != "" {
+		userAgent += " " + o.userAgentSuffix
+	}
 
 	var fallbackClient *http.Client
-	if fallbackUrl != "" {
-		fallbackClient = &http.Client{}
+	if len(o.fallbackUrls) > 0 {
+		fallbackClient = &http.Client{
+			Transport: retryClient.HTTPClient.Transport,
+			Timeout:   o.fallbackTimeout,
+		}
 	} else {
 		fallbackClient = nil
 	}
 
-	if dataBindings != "" {
-		dataBindingsContents, err := os.ReadFile(dataBindings)
+	dataBindings := ""
+	if o.dataBindings != "" {
+		dataBindingsContents, err := os.ReadFile(o.dataBindings)
 		if err != nil {
-			panic(err)
-		} else {
-			dataBindings = string(dataBindingsContents)
+			return nil, err
 		}
+		dataBindings = string(dataBindingsContents)
+	}
+
+	clientId := o.clientId
+	if clientId == "" {
+		clientId = uuid.New().String()
 	}
 
-	clientId := uuid.New().String()
+	var cache *decisionCache
+	if o.decisionCache != nil {
+		cache = newDecisionCache(*o.decisionCache)
+	}
+
+	var breaker *circuitBreaker
+	if o.circuitBreaker != nil {
+		breaker = newCircuitBreaker(*o.circuitBreaker)
+	}
+
+	credentials := o.credentials
+	if credentials == nil {
+		credentials = StaticCredentials(apiKey)
+	}
+
+	return &OsoClientImpl{
+		url:                url,
+		credentials:        credentials,
+		httpClient:         httpClient,
+		userAgent:          userAgent,
+		offset:             &offsetTracker{},
+		fallbacks:          newFallbackPool(o.fallbackUrls, o.fallbackSelection),
+		fallbackHttpClient: fallbackClient,
+		dataBindings:       dataBindings,
+		clientId:           clientId,
+		cache:              cache,
+		tracer:             newTracer(o),
+		metrics:            o.metrics,
+		retrier:            retrier,
+		breaker:            breaker,
+		probeHttpClient:    &http.Client{Transport: retryClient.HTTPClient.Transport},
+		errorPolicy:        o.errorPolicy,
+		logger:             logger,
+	}, nil
+}
+
+// The legacy constructors below can't return an error, so they panic if the
+// client can't be created (eg. the data bindings file can't be read).
+func mustNew(url string, apiKey string, opts ...Option) OsoClient {
+	client, err := New(url, apiKey, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
 
-	return OsoClientImpl{url, apiKey, retryClient.StandardClient(), userAgent, lastOffset, fallbackUrl, fallbackClient, dataBindings, clientId}
+// Create a new Oso client with a fallbackURL and custom logger
+//
+// See https://pkg.go.dev/github.com/hashicorp/go-retryablehttp@v0.7.1#LeveledLogger
+// for documentation on the logger interfaces supported.
+func NewClientWithFallbackUrlAndLoggerAndDataBindings(url string, apiKey string, fallbackUrl string, logger interface{}, dataBindings string) OsoClient {
+	return mustNew(url, apiKey, WithFallbackUrl(fallbackUrl), WithLogger(logger), WithDataBindings(dataBindings))
 }
 
 // Create a new default Oso client
 func NewClient(url string, apiKey string) OsoClient {
-	return NewClientWithFallbackUrlAndLoggerAndDataBindings(url, apiKey, "", nil, "")
+	return mustNew(url, apiKey)
 }
 
 // Create a new Oso client with a fallback URL configured
 func NewClientWithFallbackUrl(url string, apiKey string, fallbackUrl string) OsoClient {
-	return NewClientWithFallbackUrlAndLoggerAndDataBindings(url, apiKey, fallbackUrl, nil, "")
+	return mustNew(url, apiKey, WithFallbackUrl(fallbackUrl))
 }
 
 // Create a new Oso client with a custom logger
@@ -403,28 +609,28 @@ func NewClientWithFallbackUrl(url string, apiKey string, fallbackUrl string) Oso
 // See https://pkg.go.dev/github.com/hashicorp/go-retryablehttp@v0.7.1#LeveledLogger
 // for documentation on the logger interfaces supported.
 func NewClientWithLogger(url string, apiKey string, logger interface{}) OsoClient {
-	return NewClientWithFallbackUrlAndLoggerAndDataBindings(url, apiKey, "", logger, "")
+	return mustNew(url, apiKey, WithLogger(logger))
 }
 
 func NewClientWithFallbackUrlAndLogger(url string, apiKey string, fallbackUrl string, logger interface{}) OsoClient {
-	return NewClientWithFallbackUrlAndLoggerAndDataBindings(url, apiKey, fallbackUrl, logger, "")
+	return mustNew(url, apiKey, WithFallbackUrl(fallbackUrl), WithLogger(logger))
 }
 
 func NewClientWithDataBindings(url string, apiKey string, dataBindings string) OsoClient {
-	return NewClientWithFallbackUrlAndLoggerAndDataBindings(url, apiKey, "", nil, dataBindings)
+	return mustNew(url, apiKey, WithDataBindings(dataBindings))
 }
 
 func NewClientWithFallbackUrlAndDataBindings(url string, apiKey string, fallbackUrl string, dataBindings string) OsoClient {
-	return NewClientWithFallbackUrlAndLoggerAndDataBindings(url, apiKey, fallbackUrl, nil, dataBindings)
+	return mustNew(url, apiKey, WithFallbackUrl(fallbackUrl), WithDataBindings(dataBindings))
 }
 
 func NewClientWithLoggerAndDataBindings(url string, apiKey string, logger interface{}, dataBindings string) OsoClient {
-	return NewClientWithFallbackUrlAndLoggerAndDataBindings(url, apiKey, "", logger, dataBindings)
+	return mustNew(url, apiKey, WithLogger(logger), WithDataBindings(dataBindings))
 }
 
 // Check a permission depending on data both in Oso Cloud and stored in a local database:
 // Returns a SQL query to run against the local database.
-func (c OsoClientImpl) AuthorizeLocal(actor Value, action string, resource Value) (string, error) {
+func (c *OsoClientImpl) AuthorizeLocal(actor Value, action string, resource Value) (string, error) {
 	return c.AuthorizeLocalWithOptions(actor, action, resource, &AuthorizeOptions{
 		ContextFacts: []Fact{},
 		ParityHandle: nil,
@@ -433,14 +639,22 @@ func (c OsoClientImpl) AuthorizeLocal(actor Value, action string, resource Value
 
 // Check a permission depending on data both in Oso Cloud and stored in a local database:
 // Returns a SQL query to run against the local database.
-func (c OsoClientImpl) AuthorizeLocalWithContext(actor Value, action string, resource Value, contextFacts []Fact) (string, error) {
+func (c *OsoClientImpl) AuthorizeLocalWithContext(actor Value, action string, resource Value, contextFacts []Fact) (string, error) {
 	return c.AuthorizeLocalWithOptions(actor, action, resource, &AuthorizeOptions{
 		ContextFacts: contextFacts,
 		ParityHandle: nil,
 	})
 }
 
-func (c OsoClientImpl) AuthorizeLocalWithOptions(actor Value, action string, resource Value, options *AuthorizeOptions) (string, error) {
+func (c *OsoClientImpl) AuthorizeLocalWithOptions(actor Value, action string, resource Value, options *AuthorizeOptions) (string, error) {
+	return c.AuthorizeLocalCtx(context.Background(), actor, action, resource, options)
+}
+
+// Like [OsoClientImpl.AuthorizeLocalWithOptions], but the request is bound to ctx.
+func (c *OsoClientImpl) AuthorizeLocalCtx(ctx context.Context, actor Value, action string, resource Value, options *AuthorizeOptions) (string, error) {
+	if options == nil {
+		options = &AuthorizeOptions{}
+	}
 	actorT, err := toConcreteValue(actor)
 	if err != nil {
 		return "", err
@@ -449,13 +663,17 @@ func (c OsoClientImpl) AuthorizeLocalWithOptions(actor Value, action string, res
 	if err != nil {
 		return "", err
 	}
+	contextFactsT, err := mapToInternalFacts(options.ContextFacts)
+	if err != nil {
+		return "", err
+	}
 	payload := authorizeQuery{
 		ActorType:    actorT.Type,
 		ActorId:      actorT.Id,
 		Action:       action,
 		ResourceType: resourceT.Type,
 		ResourceId:   resourceT.Id,
-		ContextFacts: mapToInternalFacts(options.ContextFacts),
+		ContextFacts: contextFactsT,
 	}
 
 	var parityHandle *ParityHandle
@@ -463,7 +681,7 @@ func (c OsoClientImpl) AuthorizeLocalWithOptions(actor Value, action string, res
 		parityHandle = options.ParityHandle
 	}
 
-	resp, err := c.postAuthorizeQuery(payload, parityHandle)
+	resp, err := c.postAuthorizeQuery(ctx, payload, parityHandle)
 	if err != nil {
 		return "", err
 	}
@@ -472,27 +690,36 @@ func (c OsoClientImpl) AuthorizeLocalWithOptions(actor Value, action string, res
 
 // List authorized resources depending on data both in Oso Cloud and stored in a local database:
 // Returns a SQL query to run against the local database.
-func (c OsoClientImpl) ListLocal(actor Value, action string, resourceType string, column string) (string, error) {
+func (c *OsoClientImpl) ListLocal(actor Value, action string, resourceType string, column string) (string, error) {
 	return c.ListLocalWithContext(actor, action, resourceType, column, []Fact{})
 }
 
 // List authorized resources depending on data both in Oso Cloud and stored in a local database:
 // Returns a SQL query to run against the local database.
-func (c OsoClientImpl) ListLocalWithContext(actor Value, action string, resourceType string, column string, contextFacts []Fact) (string, error) {
+func (c *OsoClientImpl) ListLocalWithContext(actor Value, action string, resourceType string, column string, contextFacts []Fact) (string, error) {
+	return c.ListLocalCtx(context.Background(), actor, action, resourceType, column, contextFacts)
+}
+
+// Like [OsoClientImpl.ListLocalWithContext], but the request is bound to ctx.
+func (c *OsoClientImpl) ListLocalCtx(ctx context.Context, actor Value, action string, resourceType string, column string, contextFacts []Fact) (string, error) {
 	actorT, err := toConcreteValue(actor)
 	if err != nil {
 		return "", err
 	}
 
+	contextFactsT, err := mapToInternalFacts(contextFacts)
+	if err != nil {
+		return "", err
+	}
 	payload := listQuery{
 		ActorType:    actorT.Type,
 		ActorId:      actorT.Id,
 		Action:       action,
 		ResourceType: resourceType,
-		ContextFacts: mapToInternalFacts(contextFacts),
+		ContextFacts: contextFactsT,
 	}
 
-	resp, err := c.postListQuery(payload, column)
+	resp, err := c.postListQuery(ctx, payload, column)
 	if err != nil {
 		return "", err
 	}
@@ -502,14 +729,19 @@ func (c OsoClientImpl) ListLocalWithContext(actor Value, action string, resource
 // Fetches a query that can be run against your database to determine the actions
 // an actor can perform on a resource.
 // Returns a SQL query to run against the local database.
-func (c OsoClientImpl) ActionsLocal(actor Value, resource Value) (string, error) {
+func (c *OsoClientImpl) ActionsLocal(actor Value, resource Value) (string, error) {
 	return c.ActionsLocalWithContext(actor, resource, []Fact{})
 }
 
 // Fetches a query that can be run against your database to determine the actions
 // an actor can perform on a resource.
 // Returns a SQL query to run against the local database.
-func (c OsoClientImpl) ActionsLocalWithContext(actor Value, resource Value, contextFacts []Fact) (string, error) {
+func (c *OsoClientImpl) ActionsLocalWithContext(actor Value, resource Value, contextFacts []Fact) (string, error) {
+	return c.ActionsLocalCtx(context.Background(), actor, resource, contextFacts)
+}
+
+// Like [OsoClientImpl.ActionsLocalWithContext], but the request is bound to ctx.
+func (c *OsoClientImpl) ActionsLocalCtx(ctx context.Context, actor Value, resource Value, contextFacts []Fact) (string, error) {
 	actorT, err := toConcreteValue(actor)
 	if err != nil {
 		return "", err
@@ -518,15 +750,19 @@ func (c OsoClientImpl) ActionsLocalWithContext(actor Value, resource Value, cont
 	if err != nil {
 		return "", err
 	}
+	contextFactsT, err := mapToInternalFacts(contextFacts)
+	if err != nil {
+		return "", err
+	}
 	payload := actionsQuery{
 		ActorType:    actorT.Type,
 		ActorId:      actorT.Id,
 		ResourceType: resourceT.Type,
 		ResourceId:   resourceT.Id,
-		ContextFacts: mapToInternalFacts(contextFacts),
+		ContextFacts: contextFactsT,
 	}
 
-	resp, err := c.postActionsQuery(payload)
+	resp, err := c.postActionsQuery(ctx, payload)
 	if err != nil {
 		return "", err
 	}
@@ -535,7 +771,7 @@ func (c OsoClientImpl) ActionsLocalWithContext(actor Value, resource Value, cont
 
 // Determines whether or not an action is allowed, based on a combination of
 // authorization data and policy logic.
-func (c OsoClientImpl) Authorize(actor Value, action string, resource Value) (bool, error) {
+func (c *OsoClientImpl) Authorize(actor Value, action string, resource Value) (bool, error) {
 	return c.AuthorizeWithOptions(actor, action, resource, &AuthorizeOptions{
 		ContextFacts: []Fact{},
 		ParityHandle: nil,
@@ -544,14 +780,25 @@ func (c OsoClientImpl) Authorize(actor Value, action string, resource Value) (bo
 
 // Determines whether or not an action is allowed, based on a combination of
 // authorization data (including the given context facts) and policy logic.
-func (c OsoClientImpl) AuthorizeWithContext(actor Value, action string, resource Value, contextFacts []Fact) (bool, error) {
+func (c *OsoClientImpl) AuthorizeWithContext(actor Value, action string, resource Value, contextFacts []Fact) (bool, error) {
 	return c.AuthorizeWithOptions(actor, action, resource, &AuthorizeOptions{
 		ContextFacts: contextFacts,
 		ParityHandle: nil,
 	})
 }
 
-func (c OsoClientImpl) AuthorizeWithOptions(actor Value, action string, resource Value, options *AuthorizeOptions) (bool, error) {
+func (c *OsoClientImpl) AuthorizeWithOptions(actor Value, action string, resource Value, options *AuthorizeOptions) (bool, error) {
+	return c.AuthorizeCtx(context.Background(), actor, action, resource, options)
+}
+
+// Like [OsoClientImpl.AuthorizeWithOptions], but the request is bound to ctx.
+// Once ctx is cancelled or its deadline passes, the check is aborted and the
+// context's error is returned.
+func (c *OsoClientImpl) AuthorizeCtx(ctx context.Context, actor Value, action string, resource Value, options *AuthorizeOptions) (bool, error) {
+	if options == nil {
+		options = &AuthorizeOptions{}
+	}
+	options.Outcome.set(false, nil)
 	actorT, err := toConcreteValue(actor)
 	if err != nil {
 		return false, err
@@ -560,41 +807,159 @@ func (c OsoClientImpl) AuthorizeWithOptions(actor Value, action string, resource
 	if err != nil {
 		return false, err
 	}
+	contextFactsT, err := mapToInternalFacts(options.ContextFacts)
+	if err != nil {
+		return false, err
+	}
 	payload := authorizeQuery{
 		ActorType:    actorT.Type,
 		ActorId:      actorT.Id,
 		Action:       action,
 		ResourceType: resourceT.Type,
 		ResourceId:   resourceT.Id,
-		ContextFacts: mapToInternalFacts(options.ContextFacts),
+		ContextFacts: contextFactsT,
 	}
 	var parityHandle *ParityHandle
 	if options.ParityHandle != nil {
 		parityHandle = options.ParityHandle
 	}
 
-	resp, err := c.postAuthorize(payload, parityHandle)
+	callCtx, cancel := withAuthorizeTimeout(ctx, options)
+	defer cancel()
+	resp, err := c.postAuthorize(callCtx, payload, parityHandle)
 	if err != nil {
+		if allowed, handled := c.degradedDecision(ctx, actor, action, resource, err); handled {
+			options.Outcome.set(true, err)
+			return allowed, nil
+		}
 		return false, err
 	}
+	c.observeDecision(action, resp.Allowed)
 	return resp.Allowed, nil
 }
 
+// Determines which of the given resources an actor can perform an action on,
+// in a single request. Returns the authorized subset of resources, in the
+// order in which they were given.
+//
+// Only the ContextFacts of options are used: a [ParityHandle] can only check a
+// single decision, so setting one is an error.
+func (c *OsoClientImpl) AuthorizeResources(actor Value, action string, resources []Value, options *AuthorizeOptions) ([]Value, error) {
+	return c.AuthorizeResourcesCtx(context.Background(), actor, action, resources, options)
+}
+
+// Like [OsoClientImpl.AuthorizeResources], but the request is bound to ctx.
+func (c *OsoClientImpl) AuthorizeResourcesCtx(ctx context.Context, actor Value, action string, resources []Value, options *AuthorizeOptions) ([]Value, error) {
+	allowed, err := c.AuthorizeResourcesMapCtx(ctx, actor, action, resources, options)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Value, 0, len(allowed))
+	for _, resource := range resources {
+		if allowed[resource] {
+			results = append(results, resource)
+		}
+	}
+	return results, nil
+}
+
+// Like [OsoClientImpl.AuthorizeResources], but returns a map with an entry for
+// every given resource, saying whether the action is allowed on it.
+func (c *OsoClientImpl) AuthorizeResourcesMap(actor Value, action string, resources []Value, options *AuthorizeOptions) (map[Value]bool, error) {
+	return c.AuthorizeResourcesMapCtx(context.Background(), actor, action, resources, options)
+}
+
+// Like [OsoClientImpl.AuthorizeResourcesMap], but the request is bound to ctx.
+func (c *OsoClientImpl) AuthorizeResourcesMapCtx(ctx context.Context, actor Value, action string, resources []Value, options *AuthorizeOptions) (map[Value]bool, error) {
+	if options == nil {
+		options = &AuthorizeOptions{}
+	}
+	if options.ParityHandle != nil {
+		return nil, errors.New("ParityHandle is not supported for AuthorizeResources")
+	}
+	options.Outcome.set(false, nil)
+	allowed := make(map[Value]bool, len(resources))
+	if len(resources) == 0 {
+		return allowed, nil
+	}
+	actorT, err := toConcreteValue(actor)
+	if err != nil {
+		return nil, err
+	}
+	resourcesT := make([]concreteValue, 0, len(resources))
+	for _, resource := range resources {
+		resourceT, err := toConcreteValue(resource)
+		if err != nil {
+			return nil, err
+		}
+		resourcesT = append(resourcesT, *resourceT)
+		allowed[resource] = false
+	}
+	contextFactsT, err := mapToInternalFacts(options.ContextFacts)
+	if err != nil {
+		return nil, err
+	}
+	payload := authorizeResourcesQuery{
+		ActorType:    actorT.Type,
+		ActorId:      actorT.Id,
+		Action:       action,
+		Resources:    resourcesT,
+		ContextFacts: contextFactsT,
+	}
+
+	callCtx, cancel := withAuthorizeTimeout(ctx, options)
+	defer cancel()
+	resp, err := c.postAuthorizeResources(callCtx, payload)
+	if err != nil {
+		if c.errorPolicy == nil {
+			return nil, err
+		}
+		for _, resource := range resources {
+			decision, handled := c.degradedDecision(ctx, actor, action, resource, err)
+			if !handled {
+				return nil, err
+			}
+			allowed[resource] = decision
+		}
+		options.Outcome.set(true, err)
+		return allowed, nil
+	}
+	for _, result := range resp.Results {
+		resource, _ := fromValue(result)
+		if _, requested := allowed[*resource]; requested {
+			allowed[*resource] = true
+		}
+	}
+	for _, decision := range allowed {
+		c.observeDecision(action, decision)
+	}
+	return allowed, nil
+}
+
 // Fetches a list of resource ids on which an actor can perform a particular action, considering the given context facts.
-func (c OsoClientImpl) ListWithContext(actor Value, action string, resourceType string, contextFacts []Fact) ([]string, error) {
+func (c *OsoClientImpl) ListWithContext(actor Value, action string, resourceType string, contextFacts []Fact) ([]string, error) {
+	return c.ListCtx(context.Background(), actor, action, resourceType, contextFacts)
+}
+
+// Like [OsoClientImpl.ListWithContext], but the request is bound to ctx.
+func (c *OsoClientImpl) ListCtx(ctx context.Context, actor Value, action string, resourceType string, contextFacts []Fact) ([]string, error) {
 	actorT, err := toConcreteValue(actor)
 	if err != nil {
 		return nil, err
 	}
+	contextFactsT, err := mapToInternalFacts(contextFacts)
+	if err != nil {
+		return nil, err
+	}
 	payload := listQuery{
 		ActorType:    actorT.Type,
 		ActorId:      actorT.Id,
 		Action:       action,
 		ResourceType: resourceType,
-		ContextFacts: mapToInternalFacts(contextFacts),
+		ContextFacts: contextFactsT,
 	}
 
-	resp, err := c.postList(payload)
+	resp, err := c.postList(ctx, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -602,13 +967,18 @@ func (c OsoClientImpl) ListWithContext(actor Value, action string, resourceType
 }
 
 // Fetches a list of resource ids on which an actor can perform a particular action.
-func (c OsoClientImpl) List(actor Value, action string, resourceType string, contextFacts []Fact) ([]string, error) {
+func (c *OsoClientImpl) List(actor Value, action string, resourceType string, contextFacts []Fact) ([]string, error) {
 	return c.ListWithContext(actor, action, resourceType, nil)
 }
 
 // Fetches a list of actions which an actor can perform on a particular
 // resource, considering the given context facts.
-func (c OsoClientImpl) ActionsWithContext(actor Value, resource Value, contextFacts []Fact) ([]string, error) {
+func (c *OsoClientImpl) ActionsWithContext(actor Value, resource Value, contextFacts []Fact) ([]string, error) {
+	return c.ActionsCtx(context.Background(), actor, resource, contextFacts)
+}
+
+// Like [OsoClientImpl.ActionsWithContext], but the request is bound to ctx.
+func (c *OsoClientImpl) ActionsCtx(ctx context.Context, actor Value, resource Value, contextFacts []Fact) ([]string, error) {
 	actorT, err := toConcreteValue(actor)
 	if err != nil {
 		return nil, err
@@ -617,15 +987,19 @@ func (c OsoClientImpl) ActionsWithContext(actor Value, resource Value, contextFa
 	if err != nil {
 		return nil, err
 	}
+	contextFactsT, err := mapToInternalFacts(contextFacts)
+	if err != nil {
+		return nil, err
+	}
 	payload := actionsQuery{
 		ActorType:    actorT.Type,
 		ActorId:      actorT.Id,
 		ResourceType: resourceT.Type,
 		ResourceId:   resourceT.Id,
-		ContextFacts: mapToInternalFacts(contextFacts),
+		ContextFacts: contextFactsT,
 	}
 
-	resp, err := c.postActions(payload)
+	resp, err := c.postActions(ctx, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -633,17 +1007,22 @@ func (c OsoClientImpl) ActionsWithContext(actor Value, resource Value, contextFa
 }
 
 // Fetches a list of actions which an actor can perform on a particular resource.
-func (c OsoClientImpl) Actions(actor Value, resource Value) ([]string, error) {
+func (c *OsoClientImpl) Actions(actor Value, resource Value) ([]string, error) {
 	return c.ActionsWithContext(actor, resource, nil)
 }
 
 // Adds the given fact to Oso Cloud.
-func (c OsoClientImpl) Insert(fact Fact) error {
+func (c *OsoClientImpl) Insert(fact Fact) error {
+	return c.InsertCtx(context.Background(), fact)
+}
+
+// Like [OsoClientImpl.Insert], but the request is bound to ctx.
+func (c *OsoClientImpl) InsertCtx(ctx context.Context, fact Fact) error {
 	internalFact, err := toInternalFact(fact)
 	if err != nil {
 		return err
 	}
-	_, err = c.postFacts(*internalFact)
+	_, err = c.postFacts(ctx, *internalFact)
 	if err != nil {
 		return err
 	}
@@ -666,12 +1045,17 @@ func (c OsoClientImpl) Insert(fact Fact) error {
 // will delete all "has_role" facts where the first argument is the User
 // "alice", the second argument is anything, and the third argument is a
 // Repo.
-func (c OsoClientImpl) Delete(pattern IntoFactPattern) error {
+func (c *OsoClientImpl) Delete(pattern IntoFactPattern) error {
+	return c.DeleteCtx(context.Background(), pattern)
+}
+
+// Like [OsoClientImpl.Delete], but the request is bound to ctx.
+func (c *OsoClientImpl) DeleteCtx(ctx context.Context, pattern IntoFactPattern) error {
 	payload, err := pattern.intoFactPattern()
 	if err != nil {
 		return err
 	}
-	_, err = c.deleteFacts(*payload)
+	_, err = c.deleteFacts(ctx, *payload)
 	if err != nil {
 		return err
 	}
@@ -686,27 +1070,75 @@ func (c OsoClientImpl) Delete(pattern IntoFactPattern) error {
 //	  tx.Insert(NewFact("has_role", NewValue("User", "alice"), String("member"), NewValue("Repo", "anvil"))
 //	  tx.Delete(NewFactPattern("has_role", NewValue("User", "bob"), nil, nil))
 //	})
-func (c OsoClientImpl) Batch(fn func(BatchTransaction)) error {
+func (c *OsoClientImpl) Batch(fn func(BatchTransaction)) error {
+	return c.BatchCtx(context.Background(), fn)
+}
+
+// Like [OsoClientImpl.Batch], but the request is bound to ctx.
+func (c *OsoClientImpl) BatchCtx(ctx context.Context, fn func(BatchTransaction)) error {
 	tx := batchTransaction{changesets: []factChangeset{}}
 	fn(&tx)
 	if tx.batcherror != nil {
 		return tx.batcherror
 	}
-	_, err := c.postBatch(tx.changesets)
+	_, err := c.postBatch(ctx, tx.changesets)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Like [OsoClientImpl.Batch], but splits the batch into as many HTTP calls as
+// needed to stay under the request size limit, instead of failing with
+// [ErrPayloadTooLarge]. Inserts and deletes are applied in order.
+//
+// Each chunk is applied atomically, but the batch as a whole is not: if a
+// chunk fails, the chunks before it remain committed. The returned
+// [BatchResult] reports how many were.
+func (c *OsoClientImpl) BatchChunked(fn func(BatchTransaction)) (BatchResult, error) {
+	return c.BatchChunkedCtx(context.Background(), fn)
+}
+
+// Like [OsoClientImpl.BatchChunked], but the requests are bound to ctx.
+func (c *OsoClientImpl) BatchChunkedCtx(ctx context.Context, fn func(BatchTransaction)) (BatchResult, error) {
+	tx := batchTransaction{changesets: []factChangeset{}}
+	fn(&tx)
+	if tx.batcherror != nil {
+		return BatchResult{}, tx.batcherror
+	}
+	chunks, err := chunkChangesets(tx.changesets, maxBodySize)
+	if err != nil {
+		c.logger.log(levelWarn, "oso: request payload too large", "endpoint", "/batch", "error", err)
+		return BatchResult{}, err
+	}
+	if len(chunks) == 0 {
+		// Like Batch, send an empty batch as a single request, so that it
+		// still records an offset for consistent reads.
+		chunks = [][]factChangeset{tx.changesets}
+	}
+	result := BatchResult{ChunksTotal: len(chunks)}
+	for _, chunk := range chunks {
+		if _, err := c.postBatch(ctx, chunk); err != nil {
+			return result, err
+		}
+		result.ChunksCommitted++
+	}
+	return result, nil
+}
+
 // Lists facts that are stored in Oso Cloud that match the given [FactPattern].
-func (c OsoClientImpl) Get(pattern IntoFactPattern) ([]Fact, error) {
+func (c *OsoClientImpl) Get(pattern IntoFactPattern) ([]Fact, error) {
+	return c.GetCtx(context.Background(), pattern)
+}
+
+// Like [OsoClientImpl.Get], but the request is bound to ctx.
+func (c *OsoClientImpl) GetCtx(ctx context.Context, pattern IntoFactPattern) ([]Fact, error) {
 	payload, err := pattern.intoFactPattern()
 	if err != nil {
 		return nil, err
 	}
 
-	resp, e := c.getFacts(*payload)
+	resp, e := c.getFacts(ctx, *payload)
 	if e != nil {
 		return nil, e
 	}
@@ -716,9 +1148,35 @@ func (c OsoClientImpl) Get(pattern IntoFactPattern) ([]Fact, error) {
 	return mapFromInternalFacts(resp), nil
 }
 
+// Like [OsoClientImpl.Get], but streams the matching facts instead of loading
+// them all into memory. The caller must close the returned iterator.
+func (c *OsoClientImpl) GetIter(pattern IntoFactPattern, options *GetIterOptions) (*FactIterator, error) {
+	return c.GetIterCtx(context.Background(), pattern, options)
+}
+
+// Like [OsoClientImpl.GetIter], but the request is bound to ctx. Cancelling
+// ctx also stops the iteration.
+func (c *OsoClientImpl) GetIterCtx(ctx context.Context, pattern IntoFactPattern, options *GetIterOptions) (*FactIterator, error) {
+	payload, err := pattern.intoFactPattern()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.getFactsStream(ctx, *payload)
+	if err != nil {
+		return nil, err
+	}
+	return newFactIterator(body, options), nil
+}
+
 // Returns metadata about the currently active policy.
-func (c OsoClientImpl) GetPolicyMetadata() (*PolicyMetadata, error) {
-	metadata, err := c.getPolicyMetadataResult(nil)
+func (c *OsoClientImpl) GetPolicyMetadata() (*PolicyMetadata, error) {
+	return c.GetPolicyMetadataCtx(context.Background())
+}
+
+// Like [OsoClientImpl.GetPolicyMetadata], but the request is bound to ctx.
+func (c *OsoClientImpl) GetPolicyMetadataCtx(ctx context.Context) (*PolicyMetadata, error) {
+	metadata, err := c.getPolicyMetadataResult(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -727,12 +1185,17 @@ func (c OsoClientImpl) GetPolicyMetadata() (*PolicyMetadata, error) {
 
 // Updates the active policy in Oso Cloud.
 // The string passed into this function should be written in Polar.
-func (c OsoClientImpl) Policy(p string) error {
+func (c *OsoClientImpl) Policy(p string) error {
+	return c.PolicyCtx(context.Background(), p)
+}
+
+// Like [OsoClientImpl.Policy], but the request is bound to ctx.
+func (c *OsoClientImpl) PolicyCtx(ctx context.Context, p string) error {
 	payload := policy{
 		Filename: nil,
 		Src:      p,
 	}
-	_, e := c.postPolicy(payload)
+	_, e := c.postPolicy(ctx, payload)
 	if e != nil {
 		return e
 	}
@@ -742,6 +1205,6 @@ func (c OsoClientImpl) Policy(p string) error {
 // Query for an arbitrary expression:
 // Use [TypedVar] to create variables to use in the query,
 // and refer to them in the final [QueryBuilder.Evaluate] call to get their values.
-func (c OsoClientImpl) BuildQuery(query QueryFact) QueryBuilder {
+func (c *OsoClientImpl) BuildQuery(query QueryFact) QueryBuilder {
 	return newBuilder(c, query)
 }
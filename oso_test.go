@@ -1,10 +1,18 @@
 package oso
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type User struct {
@@ -144,6 +152,182 @@ func TestAPIError(t *testing.T) {
 	}
 }
 
+func TestCtxCancellation(t *testing.T) {
+	// The primary hangs until the test is over; the fallback would answer
+	// immediately if it were (wrongly) consulted.
+	release := make(chan struct{})
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer primary.Close()
+	defer close(release)
+	var fallbackCalls int32
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fallbackCalls, 1)
+		w.Write([]byte(`{"allowed": true}`))
+	}))
+	defer fallback.Close()
+
+	o, _ := New(primary.URL, "e_0123456789_12345_osotesttoken01xiIn", WithFallbackUrl(fallback.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	allowed, e := o.AuthorizeCtx(ctx, NewValue("User", "alice"), "read", NewValue("Repo", "acme"), nil)
+	if !errors.Is(e, context.DeadlineExceeded) || allowed {
+		t.Fatalf("AuthorizeCtx = %t, %v, want %t, %v", allowed, e, false, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("AuthorizeCtx took %v after the deadline passed", elapsed)
+	}
+	if n := atomic.LoadInt32(&fallbackCalls); n != 0 {
+		t.Fatalf("fallback called %d times after cancellation, want 0", n)
+	}
+
+	_, e = o.BuildQuery(NewQueryFact("allow", NewValue("User", "alice"), String("read"), NewValue("Repo", "acme"))).
+		WithCtx(ctx).
+		EvaluateExists()
+	if !errors.Is(e, context.DeadlineExceeded) {
+		t.Fatalf("EvaluateExists = %v, want %v", e, context.DeadlineExceeded)
+	}
+}
+
+func TestAuthorizeResources(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var query authorizeResourcesQuery
+		if e := json.NewDecoder(r.Body).Decode(&query); e != nil || r.URL.Path != "/api/authorize_resources" {
+			w.WriteHeader(400)
+			return
+		}
+		// Everything but "secret" is readable.
+		results := []concreteValue{}
+		for _, resource := range query.Resources {
+			if resource.Id != "secret" {
+				results = append(results, resource)
+			}
+		}
+		json.NewEncoder(w).Encode(authorizeResourcesResult{Results: results})
+	}))
+	defer server.Close()
+
+	o, _ := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn")
+	alice := NewValue("User", "alice")
+	resources := []Value{NewValue("Repo", "acme"), NewValue("Repo", "secret"), NewValue("Repo", "anvil")}
+
+	authorized, e := o.AuthorizeResources(alice, "read", resources, nil)
+	expected := []Value{NewValue("Repo", "acme"), NewValue("Repo", "anvil")}
+	if e != nil || !reflect.DeepEqual(authorized, expected) {
+		t.Fatalf("AuthorizeResources = %v, %v, want %v", authorized, e, expected)
+	}
+
+	allowed, e := o.AuthorizeResourcesMap(alice, "read", resources, nil)
+	expectedMap := map[Value]bool{resources[0]: true, resources[1]: false, resources[2]: true}
+	if e != nil || !reflect.DeepEqual(allowed, expectedMap) {
+		t.Fatalf("AuthorizeResourcesMap = %v, %v, want %v", allowed, e, expectedMap)
+	}
+
+	authorized, e = o.AuthorizeResources(alice, "read", nil, nil)
+	if e != nil || len(authorized) != 0 {
+		t.Fatalf("AuthorizeResources = %v, %v, want no results", authorized, e)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("server called %d times, want 2", n)
+	}
+}
+
+func TestGetIter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("predicate") {
+		case "has_role":
+			w.Write([]byte("["))
+			for i := 0; i < 2500; i++ {
+				if i > 0 {
+					w.Write([]byte(","))
+				}
+				fmt.Fprintf(w, `{"predicate":"has_role","args":[{"type":"User","id":"%d"},{"type":"String","id":"member"},{"type":"Repo","id":"acme"}]}`, i)
+			}
+			w.Write([]byte("]"))
+		case "is_public":
+			w.Write([]byte("null"))
+		case "truncated":
+			w.Write([]byte(`[{"predicate":"truncated","args":[]},{"predi`))
+		default:
+			w.WriteHeader(404)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	o, _ := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn")
+	it, e := o.GetIter(NewFactPattern("has_role", nil, String("member"), nil), &GetIterOptions{BufferSize: 100})
+	if e != nil {
+		t.Fatalf("GetIter failed: %v", e)
+	}
+	count := 0
+	for it.Next() {
+		fact := it.Fact()
+		if fact.Args[0] != NewValue("User", strconv.Itoa(count)) {
+			t.Fatalf("fact %d = %v", count, fact)
+		}
+		count++
+	}
+	if it.Err() != nil || count != 2500 {
+		t.Fatalf("iterated %d facts, %v, want 2500", count, it.Err())
+	}
+	if e := it.Close(); e != nil {
+		t.Fatalf("Close failed: %v", e)
+	}
+
+	it, e = o.GetIter(NewFactPattern("is_public", nil), nil)
+	if e != nil || it.Next() || it.Err() != nil {
+		t.Fatalf("GetIter on empty result = %v, %v", e, it.Err())
+	}
+
+	it, _ = o.GetIter(NewFactPattern("truncated"), nil)
+	defer it.Close()
+	if !it.Next() || it.Next() || it.Err() == nil {
+		t.Fatalf("expected one fact and then an error, got %v", it.Err())
+	}
+
+	if _, e = o.GetIter(NewFactPattern("missing"), nil); !errors.Is(e, ErrNotFound) {
+		t.Fatalf("GetIter error = %v, want ErrNotFound", e)
+	}
+}
+
+func TestGetIterTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("predicate") == "slow_headers" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		// Stream the facts for longer than the timeout.
+		w.Write([]byte("["))
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(`{"predicate":"slow_body","args":[]}]`))
+	}))
+	defer server.Close()
+
+	o, _ := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		WithTimeout(100*time.Millisecond),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 0}))
+
+	// The timeout only applies until the response headers arrive.
+	it, e := o.GetIter(NewFactPattern("slow_body"), nil)
+	if e != nil {
+		t.Fatalf("GetIter failed: %v", e)
+	}
+	defer it.Close()
+	if !it.Next() || it.Next() || it.Err() != nil {
+		t.Fatalf("expected one fact, got %v", it.Err())
+	}
+
+	if _, e = o.GetIter(NewFactPattern("slow_headers"), nil); !errors.Is(e, context.DeadlineExceeded) {
+		t.Fatalf("GetIter error = %v, want context.DeadlineExceeded", e)
+	}
+}
+
 func TestRequestBodyTooBig(t *testing.T) {
 	o := NewClient("http://localhost:8081", "e_0123456789_12345_osotesttoken01xiIn")
 	user := Value{Type: "User", ID: fmt.Sprintf("%v", idCounter)}
@@ -0,0 +1,131 @@
+// Package osogorm provides GORM scopes which filter queries down to the rows
+// an actor is authorized for, using local authorization:
+//
+//	var environments []Environment
+//	err := db.Scopes(osogorm.Authorized(client, alice, "read", "Environment")).Find(&environments).Error
+//
+// The table and primary key column are taken from the query's model, IDs are
+// bound as arguments rather than interpolated, and errors from Oso Cloud are
+// added to the query, so they surface through its Error field like any other.
+//
+// See https://www.osohq.com/docs/authorization-data/local-authorization
+package osogorm
+
+import (
+	"errors"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// An Option configures a scope.
+type Option func(*options)
+
+type options struct {
+	column       string
+	contextFacts []oso.Fact
+}
+
+// Filter on the given column, instead of the primary key of the query's
+// model. Column names are passed to Oso Cloud as they are, so qualify them
+// with the table name if the query has joins.
+func WithColumn(column string) Option {
+	return func(o *options) {
+		o.column = column
+	}
+}
+
+// Send the given context facts with the authorization query.
+func WithContextFacts(facts []oso.Fact) Option {
+	return func(o *options) {
+		o.contextFacts = facts
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Returns a scope which limits a query to the resources of the given type on
+// which actor may perform action, as decided by
+// [oso.OsoClientCtx.ListLocalParamsCtx]. Unless [WithColumn] is given, resources are
+// matched on the primary key of the query's model, which must have exactly
+// one.
+func Authorized(client oso.OsoClientCtx, actor oso.Value, action string, resourceType string, opts ...Option) func(*gorm.DB) *gorm.DB {
+	o := newOptions(opts)
+	return func(db *gorm.DB) *gorm.DB {
+		column, err := filterColumn(db, o.column)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		filter, args, err := client.ListLocalParamsCtx(db.Statement.Context, actor, action, resourceType, column, o.contextFacts, oso.PlaceholderQuestion, 0)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		return where(db, filter, args)
+	}
+}
+
+// Returns a scope which limits a query to the rows whose column satisfies the
+// given query, as decided by [oso.QueryBuilder.EvaluateLocalFilterParams]. Unless
+// [WithColumn] is given, rows are matched on the primary key of the query's
+// model. Only the column option is used; give context facts to the query
+// builder instead.
+//
+//	repo := oso.TypedVar("Repo")
+//	query := client.BuildQuery(oso.NewQueryFact("allow", alice, oso.String("read"), repo))
+//	db.Scopes(osogorm.Filter(query, repo)).Find(&repos)
+func Filter(query oso.QueryBuilder, queryVar oso.Variable, opts ...Option) func(*gorm.DB) *gorm.DB {
+	o := newOptions(opts)
+	return func(db *gorm.DB) *gorm.DB {
+		column, err := filterColumn(db, o.column)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		filter, args, err := query.WithCtx(db.Statement.Context).EvaluateLocalFilterParams(column, queryVar, oso.PlaceholderQuestion, 0)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		return where(db, filter, args)
+	}
+}
+
+// Returns the column to filter on: the given one, or the primary key of the
+// query's model, qualified with its table and quoted.
+func filterColumn(db *gorm.DB, column string) (string, error) {
+	if column != "" {
+		return column, nil
+	}
+	stmt := db.Statement
+	model := stmt.Model
+	if model == nil {
+		// Scopes run before GORM falls back to the destination.
+		model = stmt.Dest
+	}
+	if model == nil {
+		return "", errors.New("osogorm: the query has no model; use db.Model or WithColumn")
+	}
+	if err := stmt.Parse(model); err != nil {
+		return "", err
+	}
+	if len(stmt.Schema.PrimaryFields) != 1 {
+		return "", errors.New("osogorm: " + stmt.Schema.Name + " must have exactly one primary key; use WithColumn")
+	}
+	return stmt.Quote(clause.Column{Table: stmt.Table, Name: stmt.Schema.PrimaryFields[0].DBName}), nil
+}
+
+func where(db *gorm.DB, filter string, args []interface{}) *gorm.DB {
+	// GORM rewrites the filter's placeholders for its dialect. It only
+	// parenthesizes conditions that contain " OR " or " AND ", so don't rely
+	// on it.
+	return db.Where(clause.Expr{SQL: "(" + filter + ")", Vars: args})
+}
@@ -0,0 +1,136 @@
+package osogorm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type Environment struct {
+	ID     uint
+	Tenant uint
+	Name   string
+}
+
+func (Environment) TableName() string {
+	return "environment"
+}
+
+type Membership struct {
+	UserID uint `gorm:"primaryKey"`
+	TeamID uint `gorm:"primaryKey"`
+}
+
+// Returns a client whose local authorization endpoints filter on the column
+// they're given, and the columns they were given.
+func newTestClient(t *testing.T) (oso.OsoClientCtx, *[]string) {
+	t.Helper()
+	var columns []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Column string `json:"column"`
+			Mode   struct {
+				OutputColumnName string `json:"output_column_name"`
+			} `json:"mode"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/api/list_query":
+			columns = append(columns, body.Column)
+			json.NewEncoder(w).Encode(map[string]string{"sql": body.Column + " IN ('1', '2') OR name = 'who?'"})
+		case "/api/evaluate_query_local":
+			columns = append(columns, body.Mode.OutputColumnName)
+			json.NewEncoder(w).Encode(map[string]string{"sql": body.Mode.OutputColumnName + " = '3'"})
+		default:
+			w.WriteHeader(400)
+			w.Write([]byte(`{"message":"unsupported"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	client, err := oso.New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", oso.WithRetryPolicy(oso.RetryPolicy{MaxRetries: 0}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return client, &columns
+}
+
+// Returns a database that only builds SQL, without connecting.
+func newDryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("gorm.Open failed: %v", err)
+	}
+	return db
+}
+
+func TestAuthorized(t *testing.T) {
+	client, columns := newTestClient(t)
+	db := newDryRunDB(t)
+	alice := oso.NewValue("User", "alice")
+
+	var environments []Environment
+	stmt := db.Scopes(Authorized(client, alice, "read", "Environment")).Where("tenant = ?", 1).Find(&environments).Statement
+	// GORM adds parentheses of its own, since the filter contains OR.
+	expected := `SELECT * FROM "environment" WHERE tenant = $1 AND (("environment"."id" IN ($2, $3) OR name = $4))`
+	if stmt.Error != nil || stmt.SQL.String() != expected {
+		t.Fatalf("got %q, %v, want %q", stmt.SQL.String(), stmt.Error, expected)
+	}
+	if vars := []interface{}{1, "1", "2", "who?"}; !reflect.DeepEqual(stmt.Vars, vars) {
+		t.Fatalf("got vars %v, want %v", stmt.Vars, vars)
+	}
+	if len(*columns) != 1 || (*columns)[0] != `"environment"."id"` {
+		t.Fatalf("filtered on %q", *columns)
+	}
+
+	stmt = db.Model(&Environment{}).Scopes(Authorized(client, alice, "read", "Environment", WithColumn("tenant"))).Find(&environments).Statement
+	if !strings.Contains(stmt.SQL.String(), "WHERE (tenant IN") {
+		t.Fatalf("got %q, want a filter on tenant", stmt.SQL.String())
+	}
+
+	var memberships []Membership
+	err := db.Scopes(Authorized(client, alice, "read", "Membership")).Find(&memberships).Error
+	if err == nil || !strings.Contains(err.Error(), "exactly one primary key") {
+		t.Fatalf("got %v, want a primary key error", err)
+	}
+}
+
+func TestAuthorizedError(t *testing.T) {
+	client, _ := newTestClient(t)
+	db := newDryRunDB(t)
+
+	// The client rejects the invalid actor, and the error surfaces through
+	// the query.
+	var environments []Environment
+	err := db.Scopes(Authorized(client, oso.Value{}, "read", "Environment")).Find(&environments).Error
+	if err == nil {
+		t.Fatalf("Find succeeded, want error")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	client, columns := newTestClient(t)
+	db := newDryRunDB(t)
+
+	environment := oso.TypedVar("Environment")
+	query := client.BuildQuery(oso.NewQueryFact("allow", oso.NewValue("User", "alice"), oso.String("read"), environment))
+	var environments []Environment
+	stmt := db.Scopes(Filter(query, environment)).Find(&environments).Statement
+	expected := `SELECT * FROM "environment" WHERE ("environment"."id" = $1)`
+	if stmt.Error != nil || stmt.SQL.String() != expected {
+		t.Fatalf("got %q, %v, want %q", stmt.SQL.String(), stmt.Error, expected)
+	}
+	if vars := []interface{}{"3"}; !reflect.DeepEqual(stmt.Vars, vars) {
+		t.Fatalf("got vars %v, want %v", stmt.Vars, vars)
+	}
+	if len(*columns) != 1 || (*columns)[0] != `"environment"."id"` {
+		t.Fatalf("filtered on %q", *columns)
+	}
+}
@@ -0,0 +1,340 @@
+// Package osogrpc authorizes gRPC calls with Oso Cloud.
+//
+// Its server interceptors check that the actor making a call may perform the
+// call's action on the resource it targets, before passing it on:
+//
+//	actions := osogrpc.MethodActions(map[string]string{
+//		"/repos.v1.Repos/GetRepo":    "read",
+//		"/repos.v1.Repos/DeleteRepo": "delete",
+//	})
+//	actor := osogrpc.MetadataActor("User", "x-user-id")
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(osogrpc.UnaryServerInterceptor(client, actions, actor)),
+//		grpc.StreamInterceptor(osogrpc.StreamServerInterceptor(client, actions, actor)),
+//	)
+//
+// Actions can also be declared in the service definition, with a custom
+// method option; see [MethodOptionActions].
+//
+// By default, the resource of a call is taken from its request message, which
+// must implement [ResourceMessage]. Use [WithResourceResolver] to take it from
+// elsewhere.
+//
+// Denied calls fail with codes.PermissionDenied, and calls that can't be
+// authorized because Oso Cloud is unavailable (see [oso.IsUnavailable]) fail
+// with codes.Unavailable. Calls whose actor or resource Oso Cloud rejects as
+// invalid fail with codes.InvalidArgument, and any other failed check with
+// codes.Internal. The details of failed checks are logged with grpclog, and
+// not sent to callers.
+//
+// It's a separate module, so that only programs which use it depend on gRPC.
+package osogrpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// An ActionMapper returns the action performed by calls to the given method,
+// eg. "/repos.v1.Repos/GetRepo". It returns false for methods that have no
+// action, which are denied.
+type ActionMapper func(fullMethod string) (string, bool)
+
+// Returns an action mapper which looks up methods in the given table, keyed by
+// full method name.
+func MethodActions(table map[string]string) ActionMapper {
+	return func(fullMethod string) (string, bool) {
+		action, ok := table[fullMethod]
+		return action, ok
+	}
+}
+
+// Returns an action mapper which takes the action of a method from a string
+// method option, eg.
+//
+//	extend google.protobuf.MethodOptions {
+//	  string oso_action = 50000;
+//	}
+//
+//	service Repos {
+//	  rpc GetRepo(GetRepoRequest) returns (Repo) {
+//	    option (oso_action) = "read";
+//	  }
+//	}
+//
+// where ext is the generated extension, eg. E_OsoAction. Services are looked
+// up in the global protobuf registry, which generated code registers with.
+func MethodOptionActions(ext protoreflect.ExtensionType) ActionMapper {
+	return methodOptionActions(protoregistry.GlobalFiles, ext)
+}
+
+func methodOptionActions(files *protoregistry.Files, ext protoreflect.ExtensionType) ActionMapper {
+	var cache sync.Map // full method -> action, or "" if there is none
+	return func(fullMethod string) (string, bool) {
+		if action, ok := cache.Load(fullMethod); ok {
+			return action.(string), action != ""
+		}
+		action := ""
+		name := protoreflect.FullName(strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1))
+		if descriptor, err := files.FindDescriptorByName(name); err == nil {
+			if method, ok := descriptor.(protoreflect.MethodDescriptor); ok && method.Options() != nil {
+				action, _ = proto.GetExtension(method.Options(), ext).(string)
+			}
+		}
+		cache.Store(fullMethod, action)
+		return action, action != ""
+	}
+}
+
+// An ActorExtractor returns the actor making a call, eg. from its metadata.
+type ActorExtractor func(ctx context.Context, md metadata.MD) (oso.Value, error)
+
+// Returns an actor extractor which takes the ID of an actor of the given type
+// from the first value of the given metadata key.
+func MetadataActor(actorType string, key string) ActorExtractor {
+	return func(ctx context.Context, md metadata.MD) (oso.Value, error) {
+		values := md.Get(key)
+		if len(values) == 0 || values[0] == "" {
+			return oso.Value{}, errors.New("osogrpc: no " + key + " metadata")
+		}
+		return oso.NewValue(actorType, values[0]), nil
+	}
+}
+
+// A ResourceResolver returns the resource a call acts on. req is the request
+// message, or for streaming calls, the first message received from the client.
+type ResourceResolver interface {
+	Resource(ctx context.Context, fullMethod string, req interface{}) (oso.Value, error)
+}
+
+// Adapts a function to a [ResourceResolver].
+type ResourceResolverFunc func(ctx context.Context, fullMethod string, req interface{}) (oso.Value, error)
+
+func (f ResourceResolverFunc) Resource(ctx context.Context, fullMethod string, req interface{}) (oso.Value, error) {
+	return f(ctx, fullMethod, req)
+}
+
+// A request message which knows the resource it acts on. Implement it
+// alongside the generated code, eg.
+//
+//	func (r *GetRepoRequest) OsoResource() oso.Value {
+//		return oso.NewValue("Repo", r.GetRepoId())
+//	}
+type ResourceMessage interface {
+	OsoResource() oso.Value
+}
+
+type messageResolver struct{}
+
+func (messageResolver) Resource(ctx context.Context, fullMethod string, req interface{}) (oso.Value, error) {
+	if message, ok := req.(ResourceMessage); ok {
+		return message.OsoResource(), nil
+	}
+	return oso.Value{}, errors.New("osogrpc: request message doesn't implement ResourceMessage")
+}
+
+// An Option configures the interceptors.
+type Option func(*options)
+
+type options struct {
+	resources ResourceResolver
+	skip      map[string]bool
+}
+
+// Resolve the resource of each call with the given resolver, instead of
+// asking the request message for it.
+func WithResourceResolver(resolver ResourceResolver) Option {
+	return func(o *options) {
+		o.resources = resolver
+	}
+}
+
+// Pass on calls to the given methods without authorizing them, eg. health
+// checks and reflection.
+func WithSkipMethods(fullMethods ...string) Option {
+	return func(o *options) {
+		for _, method := range fullMethods {
+			o.skip[method] = true
+		}
+	}
+}
+
+type authorizer struct {
+	client  oso.OsoClientCtx
+	actions ActionMapper
+	actor   ActorExtractor
+	options
+}
+
+func newAuthorizer(client oso.OsoClientCtx, actions ActionMapper, actor ActorExtractor, opts []Option) *authorizer {
+	a := &authorizer{
+		client:  client,
+		actions: actions,
+		actor:   actor,
+		options: options{resources: messageResolver{}, skip: map[string]bool{}},
+	}
+	for _, opt := range opts {
+		opt(&a.options)
+	}
+	return a
+}
+
+// Returns a status error if the call isn't allowed, and otherwise a context
+// with its actor.
+func (a *authorizer) authorize(ctx context.Context, fullMethod string, req interface{}) (context.Context, error) {
+	action, ok := a.actions(fullMethod)
+	if !ok {
+		return nil, status.Errorf(codes.PermissionDenied, "osogrpc: no action for %s", fullMethod)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	actor, err := a.actor(ctx, md)
+	if err != nil {
+		return nil, statusOr(err, codes.Unauthenticated)
+	}
+	resource, err := a.resources.Resource(ctx, fullMethod, req)
+	if err != nil {
+		return nil, statusOr(err, codes.InvalidArgument)
+	}
+	allowed, err := a.client.AuthorizeCtx(ctx, actor, action, resource, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, checkFailed(fullMethod, err)
+	}
+	if !allowed {
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+	return context.WithValue(ctx, actorKey{}, actor), nil
+}
+
+var logger = grpclog.Component("osogrpc")
+
+// Logs err, from the check of a call to fullMethod, and returns a status
+// error with a code for the kind of failure but none of its details.
+func checkFailed(fullMethod string, err error) error {
+	logger.Warningf("authorizing %s failed: %v", fullMethod, err)
+	switch {
+	case errors.Is(err, oso.ErrInvalidValue):
+		return status.Error(codes.InvalidArgument, "osogrpc: invalid actor or resource")
+	case oso.IsUnavailable(err):
+		return status.Error(codes.Unavailable, "osogrpc: authorization is unavailable")
+	}
+	return status.Error(codes.Internal, "osogrpc: authorization failed")
+}
+
+// Returns err if it's already a status error, and otherwise wraps it in one
+// with the given code.
+func statusOr(err error, code codes.Code) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(code, err.Error())
+}
+
+// Returns a unary server interceptor which authorizes each call before
+// passing it on.
+func UnaryServerInterceptor(client oso.OsoClientCtx, actions ActionMapper, actor ActorExtractor, opts ...Option) grpc.UnaryServerInterceptor {
+	a := newAuthorizer(client, actions, actor, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if a.skip[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		ctx, err := a.authorize(ctx, info.FullMethod, req)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Returns a stream server interceptor which authorizes each call when the
+// handler receives the first message from the client, since that's where the
+// resource is usually found. If the call isn't allowed, receiving fails with
+// the status error, which the handler should return.
+//
+// The actor is only available from [ActorFromContext] once the first message
+// has been received.
+func StreamServerInterceptor(client oso.OsoClientCtx, actions ActionMapper, actor ActorExtractor, opts ...Option) grpc.StreamServerInterceptor {
+	a := newAuthorizer(client, actions, actor, opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if a.skip[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		return handler(srv, &authorizedStream{ServerStream: ss, authorizer: a, fullMethod: info.FullMethod, ctx: ss.Context()})
+	}
+}
+
+type authorizedStream struct {
+	grpc.ServerStream
+	authorizer *authorizer
+	fullMethod string
+	mu         sync.Mutex
+	authorized bool
+	err        error
+	ctx        context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctx
+}
+
+func (s *authorizedStream) RecvMsg(m interface{}) error {
+	s.mu.Lock()
+	if s.err != nil {
+		defer s.mu.Unlock()
+		return s.err
+	}
+	authorized := s.authorized
+	s.mu.Unlock()
+
+	if err := s.ServerStream.RecvMsg(m); err != nil || authorized {
+		return err
+	}
+	ctx, err := s.authorizer.authorize(s.ServerStream.Context(), s.fullMethod, m)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.err = err
+		return err
+	}
+	s.authorized = true
+	s.ctx = ctx
+	return nil
+}
+
+func (s *authorizedStream) SendMsg(m interface{}) error {
+	s.mu.Lock()
+	authorized, err := s.authorized, s.err
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !authorized {
+		return status.Error(codes.PermissionDenied, "osogrpc: stream must receive a message to be authorized before sending")
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+type actorKey struct{}
+
+// Returns the actor of a call that was authorized by one of the interceptors.
+func ActorFromContext(ctx context.Context) (oso.Value, bool) {
+	actor, ok := ctx.Value(actorKey{}).(oso.Value)
+	return actor, ok
+}
@@ -0,0 +1,282 @@
+package osogrpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"github.com/osohq/go-oso-cloud/v2/osotest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// A test service without generated code: each method takes the ID of a repo,
+// as a StringValue.
+const (
+	getMethod    = "/osogrpc.test.Repos/Get"
+	deleteMethod = "/osogrpc.test.Repos/Delete"
+	healthMethod = "/osogrpc.test.Repos/Health"
+	watchMethod  = "/osogrpc.test.Repos/Watch"
+)
+
+var repoResolver = ResourceResolverFunc(func(ctx context.Context, fullMethod string, req interface{}) (oso.Value, error) {
+	return oso.NewValue("Repo", req.(*wrapperspb.StringValue).GetValue()), nil
+})
+
+func unaryHandler(name string) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := &wrapperspb.StringValue{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				actor, _ := ActorFromContext(ctx)
+				return wrapperspb.String(actor.ID), nil
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/osogrpc.test.Repos/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+var reposService = grpc.ServiceDesc{
+	ServiceName: "osogrpc.test.Repos",
+	HandlerType: (*interface{})(nil),
+	Methods:     []grpc.MethodDesc{unaryHandler("Get"), unaryHandler("Delete"), unaryHandler("Health")},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Watch",
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			req := &wrapperspb.StringValue{}
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			actor, _ := ActorFromContext(stream.Context())
+			for i := 0; i < 2; i++ {
+				if err := stream.SendMsg(wrapperspb.String(actor.ID + " watches " + req.GetValue())); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}},
+}
+
+func newTestConn(t *testing.T, client oso.OsoClientCtx, actions ActionMapper) *grpc.ClientConn {
+	t.Helper()
+	actor := MetadataActor("User", "x-user")
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(client, actions, actor, WithResourceResolver(repoResolver), WithSkipMethods(healthMethod))),
+		grpc.StreamInterceptor(StreamServerInterceptor(client, actions, actor, WithResourceResolver(repoResolver))),
+	)
+	server.RegisterService(&reposService, struct{}{})
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("DialContext failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func newTestClient() *osotest.Client {
+	client := osotest.NewClient()
+	client.AddRole("Repo", "reader", "read")
+	client.Insert(oso.NewFact("has_role", oso.NewValue("User", "bob"), oso.String("reader"), oso.NewValue("Repo", "acme")))
+	return client
+}
+
+var testActions = MethodActions(map[string]string{
+	getMethod:    "read",
+	deleteMethod: "delete",
+	watchMethod:  "read",
+})
+
+func asUser(user string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-user", user)
+}
+
+func invoke(conn *grpc.ClientConn, ctx context.Context, method string, repo string) (string, codes.Code) {
+	res := &wrapperspb.StringValue{}
+	err := conn.Invoke(ctx, method, wrapperspb.String(repo), res)
+	return res.GetValue(), status.Code(err)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	conn := newTestConn(t, newTestClient(), testActions)
+
+	cases := []struct {
+		ctx    context.Context
+		method string
+		repo   string
+		code   codes.Code
+		actor  string
+	}{
+		{asUser("bob"), getMethod, "acme", codes.OK, "bob"},
+		{asUser("bob"), deleteMethod, "acme", codes.PermissionDenied, ""},
+		{asUser("bob"), getMethod, "other", codes.PermissionDenied, ""},
+		{context.Background(), getMethod, "acme", codes.Unauthenticated, ""},
+		{context.Background(), healthMethod, "acme", codes.OK, ""},
+	}
+	for _, c := range cases {
+		actor, code := invoke(conn, c.ctx, c.method, c.repo)
+		if code != c.code || actor != c.actor {
+			t.Errorf("%s(%s): got %v and %q, want %v and %q", c.method, c.repo, code, actor, c.code, c.actor)
+		}
+	}
+}
+
+type failingClient struct {
+	oso.OsoClientCtx
+	err error
+}
+
+func (c failingClient) AuthorizeCtx(context.Context, oso.Value, string, oso.Value, *oso.AuthorizeOptions) (bool, error) {
+	return false, c.err
+}
+
+func TestUnaryServerInterceptorErrors(t *testing.T) {
+	cases := []struct {
+		err  error
+		code codes.Code
+	}{
+		{&oso.APIError{StatusCode: 503, Message: "secret detail"}, codes.Unavailable},
+		{&url.Error{Op: "Post", URL: "https://cloud.osohq.com", Err: io.ErrUnexpectedEOF}, codes.Unavailable},
+		{&oso.APIError{StatusCode: 401, Message: "secret detail"}, codes.Internal},
+		{fmt.Errorf("secret detail: %w", oso.ErrInvalidValue), codes.InvalidArgument},
+	}
+	for _, c := range cases {
+		conn := newTestConn(t, failingClient{newTestClient(), c.err}, testActions)
+		err := conn.Invoke(asUser("bob"), getMethod, wrapperspb.String("acme"), &wrapperspb.StringValue{})
+		if status.Code(err) != c.code || strings.Contains(err.Error(), "secret") {
+			t.Errorf("%v: got %v, want %v without the error's details", c.err, err, c.code)
+		}
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	conn := newTestConn(t, newTestClient(), testActions)
+	watch := func(user string, repo string) ([]string, codes.Code) {
+		stream, err := conn.NewStream(asUser(user), &reposService.Streams[0], watchMethod)
+		if err != nil {
+			t.Fatalf("NewStream failed: %v", err)
+		}
+		if err := stream.SendMsg(wrapperspb.String(repo)); err != nil {
+			t.Fatalf("SendMsg failed: %v", err)
+		}
+		stream.CloseSend()
+		var messages []string
+		for {
+			res := &wrapperspb.StringValue{}
+			if err := stream.RecvMsg(res); err == io.EOF {
+				return messages, codes.OK
+			} else if err != nil {
+				return messages, status.Code(err)
+			}
+			messages = append(messages, res.GetValue())
+		}
+	}
+
+	if messages, code := watch("bob", "acme"); code != codes.OK || len(messages) != 2 || messages[0] != "bob watches acme" {
+		t.Fatalf("got %q and %v, want two messages and %v", messages, code, codes.OK)
+	}
+	if messages, code := watch("bob", "other"); code != codes.PermissionDenied || len(messages) != 0 {
+		t.Fatalf("got %q and %v, want no messages and %v", messages, code, codes.PermissionDenied)
+	}
+}
+
+func TestMethodOptionActions(t *testing.T) {
+	// Build the equivalent of
+	//
+	//	extend google.protobuf.MethodOptions { string oso_action = 50000; }
+	//	service Repos { rpc Get(StringValue) returns (StringValue) { option (oso_action) = "read"; } }
+	files := &protoregistry.Files{}
+	files.RegisterFile(descriptorpb.File_google_protobuf_descriptor_proto)
+	files.RegisterFile(wrapperspb.File_google_protobuf_wrappers_proto)
+	files.RegisterFile(emptypb.File_google_protobuf_empty_proto)
+	extFile, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("osogrpc/options.proto"),
+		Package:    proto.String("osogrpc.test"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		Extension: []*descriptorpb.FieldDescriptorProto{{
+			Name:     proto.String("oso_action"),
+			Number:   proto.Int32(50000),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			Extendee: proto.String(".google.protobuf.MethodOptions"),
+			JsonName: proto.String("osoAction"),
+		}},
+	}, files)
+	if err != nil {
+		t.Fatalf("NewFile failed: %v", err)
+	}
+	files.RegisterFile(extFile)
+	ext := dynamicpb.NewExtensionType(extFile.Extensions().Get(0))
+
+	readOptions := &descriptorpb.MethodOptions{}
+	proto.SetExtension(readOptions, ext, "read")
+	method := func(name string, options *descriptorpb.MethodOptions) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".google.protobuf.StringValue"),
+			OutputType: proto.String(".google.protobuf.StringValue"),
+			Options:    options,
+		}
+	}
+	serviceFile, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("osogrpc/repos.proto"),
+		Package:    proto.String("osogrpc.test"),
+		Dependency: []string{"google/protobuf/wrappers.proto", "osogrpc/options.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name:   proto.String("Repos"),
+			Method: []*descriptorpb.MethodDescriptorProto{method("Get", readOptions), method("Delete", nil)},
+		}},
+	}, files)
+	if err != nil {
+		t.Fatalf("NewFile failed: %v", err)
+	}
+	files.RegisterFile(serviceFile)
+
+	actions := methodOptionActions(files, ext)
+	if action, ok := actions(getMethod); action != "read" || !ok {
+		t.Fatalf("action of %s = %q, %t, want %q", getMethod, action, ok, "read")
+	}
+	for _, m := range []string{deleteMethod, "/osogrpc.test.Missing/Get"} {
+		if action, ok := actions(m); ok {
+			t.Fatalf("action of %s = %q, want none", m, action)
+		}
+	}
+
+	conn := newTestConn(t, newTestClient(), actions)
+	if _, code := invoke(conn, asUser("bob"), getMethod, "acme"); code != codes.OK {
+		t.Fatalf("got %v, want %v", code, codes.OK)
+	}
+	if _, code := invoke(conn, asUser("bob"), deleteMethod, "acme"); code != codes.PermissionDenied {
+		t.Fatalf("got %v, want %v", code, codes.PermissionDenied)
+	}
+}
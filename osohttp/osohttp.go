@@ -0,0 +1,413 @@
+// Package osohttp authorizes net/http requests with Oso Cloud.
+//
+// [Middleware] checks that the actor making a request may perform an action on
+// the resource it targets, before passing it on:
+//
+//	authorize := osohttp.Middleware(client, currentUser, osohttp.PathValue("Repo", "/repos/"),
+//		osohttp.WithActionMapper(osohttp.MethodActions()),
+//	)
+//	mux.Handle("/repos/", authorize(reposHandler))
+//
+// The actor and resource are taken from the request by [Extractor] functions,
+// and the action by an [ActionMapper]. Requests without an actor get a 401,
+// denied requests get a 403 (or a 404, see [WithNotFound]), and requests that
+// can't be authorized because Oso Cloud is unavailable get a 503. Each
+// response can be replaced.
+//
+// [LoadActions] instead fetches every action the actor may perform on the
+// resource, for handlers that render different views depending on them.
+package osohttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+)
+
+// An Extractor takes the actor or the resource of a request from it, eg. from
+// the session or the URL.
+type Extractor func(r *http.Request) (oso.Value, error)
+
+// An ActionMapper decides which action a request performs.
+type ActionMapper func(r *http.Request) (string, error)
+
+// Returned by an [ActionMapper] which has no action for a request.
+var ErrNoAction = errors.New("osohttp: no action for request")
+
+// Returns an extractor which always returns value, eg. for a route which acts
+// on a single global resource.
+func Constant(value oso.Value) Extractor {
+	return func(*http.Request) (oso.Value, error) {
+		return value, nil
+	}
+}
+
+// Returns an extractor which takes the ID of a resource of the given type from
+// the path segment following prefix. For "/repos/acme/issues" and the prefix
+// "/repos/", the ID is "acme".
+func PathValue(resourceType string, prefix string) Extractor {
+	return func(r *http.Request) (oso.Value, error) {
+		rest := strings.TrimPrefix(r.URL.Path, prefix)
+		if rest == r.URL.Path && prefix != "" {
+			return oso.Value{}, errors.New("osohttp: path " + r.URL.Path + " doesn't start with " + prefix)
+		}
+		id := strings.SplitN(rest, "/", 2)[0]
+		if id == "" {
+			return oso.Value{}, errors.New("osohttp: path " + r.URL.Path + " has no " + resourceType + " ID")
+		}
+		return oso.NewValue(resourceType, id), nil
+	}
+}
+
+// Returns an action mapper which maps request methods to the conventional
+// CRUD actions: GET and HEAD to "read", POST to "create", PUT and PATCH to
+// "update", and DELETE to "delete".
+func MethodActions() ActionMapper {
+	return func(r *http.Request) (string, error) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			return "read", nil
+		case http.MethodPost:
+			return "create", nil
+		case http.MethodPut, http.MethodPatch:
+			return "update", nil
+		case http.MethodDelete:
+			return "delete", nil
+		}
+		return "", ErrNoAction
+	}
+}
+
+// Returns an action mapper which looks up the action of a request in a table.
+// Keys are a method and a path prefix, like "POST /repos/", or just a path
+// prefix to match any method. The longest matching prefix wins, and a key with
+// a method beats one without.
+//
+//	osohttp.RouteActions(map[string]string{
+//		"/repos/":             "read",
+//		"POST /repos/":        "create_issue",
+//		"DELETE /repos/":      "delete",
+//		"POST /repos/archive": "archive",
+//	})
+func RouteActions(routes map[string]string) ActionMapper {
+	type route struct {
+		method string
+		prefix string
+		action string
+	}
+	table := make([]route, 0, len(routes))
+	for key, action := range routes {
+		method, prefix := "", key
+		if i := strings.IndexByte(key, ' '); i >= 0 {
+			method, prefix = key[:i], strings.TrimSpace(key[i+1:])
+		}
+		table = append(table, route{method, prefix, action})
+	}
+	// Longest prefixes first, and for equal prefixes, those with a method.
+	sort.Slice(table, func(i, j int) bool {
+		if len(table[i].prefix) != len(table[j].prefix) {
+			return len(table[i].prefix) > len(table[j].prefix)
+		}
+		return table[i].method > table[j].method
+	})
+	return func(r *http.Request) (string, error) {
+		for _, route := range table {
+			if (route.method == "" || route.method == r.Method) && strings.HasPrefix(r.URL.Path, route.prefix) {
+				return route.action, nil
+			}
+		}
+		return "", ErrNoAction
+	}
+}
+
+// An Option configures [Middleware] or [LoadActions].
+type Option func(*options)
+
+type options struct {
+	actions      ActionMapper
+	contextFacts func(r *http.Request) ([]oso.Fact, error)
+	notFound     string
+	onForbidden  http.Handler
+	onNotFound   http.Handler
+	onNoActor    func(w http.ResponseWriter, r *http.Request, err error)
+	onBadRequest func(w http.ResponseWriter, r *http.Request, err error)
+	onError      func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+func defaultOptions() options {
+	return options{
+		actions:     MethodActions(),
+		onForbidden: statusHandler(http.StatusForbidden),
+		onNotFound:  statusHandler(http.StatusNotFound),
+		onNoActor: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		},
+		onBadRequest: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		},
+		onError: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		},
+	}
+}
+
+func statusHandler(code int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(code), code)
+	})
+}
+
+// Decide the action of each request with the given mapper. Defaults to
+// [MethodActions].
+func WithActionMapper(mapper ActionMapper) Option {
+	return func(o *options) {
+		o.actions = mapper
+	}
+}
+
+// Send the facts returned by fn with each check, as context facts. They can
+// describe the request itself, eg. the network it came from.
+func WithContextFacts(fn func(r *http.Request) ([]oso.Fact, error)) Option {
+	return func(o *options) {
+		o.contextFacts = fn
+	}
+}
+
+// Respond to denied requests as if the resource didn't exist, unless the actor
+// may perform readAction on it, so that the response doesn't reveal which
+// resources exist.
+func WithNotFound(readAction string) Option {
+	return func(o *options) {
+		o.notFound = readAction
+	}
+}
+
+// Respond to denied requests with the given handlers. The defaults respond
+// with a plain 403 or 404.
+func WithDeniedHandlers(forbidden http.Handler, notFound http.Handler) Option {
+	return func(o *options) {
+		if forbidden != nil {
+			o.onForbidden = forbidden
+		}
+		if notFound != nil {
+			o.onNotFound = notFound
+		}
+	}
+}
+
+// Respond to requests whose actor can't be determined, eg. because nobody is
+// logged in, with the given handler. The default responds with a plain 401.
+func WithUnauthorizedHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) Option {
+	return func(o *options) {
+		o.onNoActor = handler
+	}
+}
+
+// Respond to requests whose resource, action or context facts can't be
+// determined, or which Oso Cloud rejects as invalid (see
+// [oso.ErrInvalidValue]), with the given handler. The default responds with a
+// plain 400.
+func WithBadRequestHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) Option {
+	return func(o *options) {
+		o.onBadRequest = handler
+	}
+}
+
+// Respond to requests that can't be authorized because the check failed for
+// any other reason, eg. because Oso Cloud is unavailable, with the given
+// handler. The default responds with a plain 503.
+func WithErrorHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) Option {
+	return func(o *options) {
+		o.onError = handler
+	}
+}
+
+type request struct {
+	actor        oso.Value
+	resource     oso.Value
+	contextFacts []oso.Fact
+}
+
+// Returned by parse when the actor extractor fails, or returns an incomplete
+// actor.
+type noActorError struct {
+	err error
+}
+
+func (e noActorError) Error() string { return e.err.Error() }
+func (e noActorError) Unwrap() error { return e.err }
+
+func (o *options) parse(r *http.Request, actor Extractor, resource Extractor) (*request, error) {
+	actorValue, err := actor(r)
+	if err != nil {
+		return nil, noActorError{err}
+	}
+	if actorValue.Type == "" || actorValue.ID == "" {
+		return nil, noActorError{errors.New("osohttp: request has no actor")}
+	}
+	resourceValue, err := resource(r)
+	if err != nil {
+		return nil, err
+	}
+	req := &request{actor: actorValue, resource: resourceValue}
+	if o.contextFacts != nil {
+		if req.contextFacts, err = o.contextFacts(r); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// Returns middleware which only passes on requests whose actor may perform
+// the request's action on its resource. The actor, resource and action are
+// stored in the request's context, see [ActorFromContext],
+// [ResourceFromContext] and [ActionFromContext].
+func Middleware(client oso.OsoClientCtx, actor Extractor, resource Extractor, opts ...Option) func(http.Handler) http.Handler {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := o.parse(r, actor, resource)
+			if err != nil {
+				o.rejected(w, r, err)
+				return
+			}
+			action, err := o.actions(r)
+			if err != nil {
+				o.onBadRequest(w, r, err)
+				return
+			}
+
+			ctx := r.Context()
+			options := &oso.AuthorizeOptions{ContextFacts: req.contextFacts}
+			allowed, err := client.AuthorizeCtx(ctx, req.actor, action, req.resource, options)
+			if err != nil {
+				o.failed(w, r, err)
+				return
+			}
+			if !allowed {
+				if o.notFound == "" || o.notFound == action {
+					o.denied(w, r, o.notFound == action)
+					return
+				}
+				canRead, err := client.AuthorizeCtx(ctx, req.actor, o.notFound, req.resource, options)
+				if err != nil {
+					o.failed(w, r, err)
+					return
+				}
+				o.denied(w, r, !canRead)
+				return
+			}
+
+			ctx = context.WithValue(ctx, actorKey{}, req.actor)
+			ctx = context.WithValue(ctx, resourceKey{}, req.resource)
+			ctx = context.WithValue(ctx, actionKey{}, action)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Responds to a request that parse failed on.
+func (o *options) rejected(w http.ResponseWriter, r *http.Request, err error) {
+	var noActor noActorError
+	if errors.As(err, &noActor) {
+		o.onNoActor(w, r, noActor.err)
+	} else {
+		o.onBadRequest(w, r, err)
+	}
+}
+
+// Responds to a request whose check failed with err.
+func (o *options) failed(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, oso.ErrInvalidValue) {
+		o.onBadRequest(w, r, err)
+	} else {
+		o.onError(w, r, err)
+	}
+}
+
+func (o *options) denied(w http.ResponseWriter, r *http.Request, notFound bool) {
+	if notFound {
+		o.onNotFound.ServeHTTP(w, r)
+	} else {
+		o.onForbidden.ServeHTTP(w, r)
+	}
+}
+
+// Returns middleware which fetches the actions the actor may perform on the
+// resource, and stores them in the request's context for the next handler,
+// see [ActionsFromContext] and [Can]. Unlike [Middleware], it passes on every
+// request, even if the actor may perform no actions. The action mapper and
+// not-found options are not used.
+func LoadActions(client oso.OsoClientCtx, actor Extractor, resource Extractor, opts ...Option) func(http.Handler) http.Handler {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := o.parse(r, actor, resource)
+			if err != nil {
+				o.rejected(w, r, err)
+				return
+			}
+			actions, err := client.ActionsCtx(r.Context(), req.actor, req.resource, req.contextFacts)
+			if err != nil {
+				o.failed(w, r, err)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), actorKey{}, req.actor)
+			ctx = context.WithValue(ctx, resourceKey{}, req.resource)
+			ctx = context.WithValue(ctx, actionsKey{}, actions)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type actorKey struct{}
+type resourceKey struct{}
+type actionKey struct{}
+type actionsKey struct{}
+
+// Returns the actor of a request passed on by [Middleware] or [LoadActions].
+func ActorFromContext(ctx context.Context) (oso.Value, bool) {
+	actor, ok := ctx.Value(actorKey{}).(oso.Value)
+	return actor, ok
+}
+
+// Returns the resource of a request passed on by [Middleware] or
+// [LoadActions].
+func ResourceFromContext(ctx context.Context) (oso.Value, bool) {
+	resource, ok := ctx.Value(resourceKey{}).(oso.Value)
+	return resource, ok
+}
+
+// Returns the action that [Middleware] authorized for a request.
+func ActionFromContext(ctx context.Context) (string, bool) {
+	action, ok := ctx.Value(actionKey{}).(string)
+	return action, ok
+}
+
+// Returns the actions stored by [LoadActions].
+func ActionsFromContext(ctx context.Context) ([]string, bool) {
+	actions, ok := ctx.Value(actionsKey{}).([]string)
+	return actions, ok
+}
+
+// Reports whether action is among the actions stored by [LoadActions].
+func Can(ctx context.Context, action string) bool {
+	actions, _ := ActionsFromContext(ctx)
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package osohttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"github.com/osohq/go-oso-cloud/v2/osotest"
+)
+
+func currentUser(r *http.Request) (oso.Value, error) {
+	user := r.Header.Get("X-User")
+	if user == "" {
+		return oso.Value{}, errors.New("not logged in")
+	}
+	return oso.NewValue("User", user), nil
+}
+
+func newTestClient() *osotest.Client {
+	client := osotest.NewClient()
+	client.AddRole("Repo", "reader", "read")
+	client.AddRole("Repo", "maintainer", "read", "delete", "archive")
+	client.Insert(oso.NewFact("has_role", oso.NewValue("User", "alice"), oso.String("maintainer"), oso.NewValue("Repo", "acme")))
+	client.Insert(oso.NewFact("has_role", oso.NewValue("User", "bob"), oso.String("reader"), oso.NewValue("Repo", "acme")))
+	return client
+}
+
+func serve(handler http.Handler, method string, path string, user string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if user != "" {
+		r.Header.Set("X-User", user)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	client := newTestClient()
+	var authorized string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, _ := ActorFromContext(r.Context())
+		resource, _ := ResourceFromContext(r.Context())
+		action, _ := ActionFromContext(r.Context())
+		authorized = actor.ID + " " + action + " " + resource.ID
+	})
+	handler := Middleware(client, currentUser, PathValue("Repo", "/repos/"),
+		WithActionMapper(RouteActions(map[string]string{
+			"/repos/":              "read",
+			"DELETE /repos/":       "delete",
+			"POST /repos/":         "archive",
+			"POST /repos/x/create": "create",
+		})),
+		WithNotFound("read"),
+	)(next)
+
+	cases := []struct {
+		method, path, user string
+		code               int
+		authorized         string
+	}{
+		{"GET", "/repos/acme", "alice", 200, "alice read acme"},
+		{"DELETE", "/repos/acme", "alice", 200, "alice delete acme"},
+		{"GET", "/repos/acme/issues", "bob", 200, "bob read acme"},
+		// Bob can see the repo, so learns that he may not delete it...
+		{"DELETE", "/repos/acme", "bob", 403, ""},
+		// ...but Carol can't even tell whether it exists.
+		{"DELETE", "/repos/acme", "carol", 404, ""},
+		{"GET", "/repos/acme", "carol", 404, ""},
+		{"GET", "/repos/acme", "", 401, ""},
+		{"GET", "/repos/", "alice", 400, ""},
+	}
+	for _, c := range cases {
+		authorized = ""
+		w := serve(handler, c.method, c.path, c.user)
+		if w.Code != c.code || authorized != c.authorized {
+			t.Errorf("%s %s as %q: got %d and %q, want %d and %q", c.method, c.path, c.user, w.Code, authorized, c.code, c.authorized)
+		}
+	}
+}
+
+type failingClient struct {
+	oso.OsoClientCtx
+}
+
+func (failingClient) AuthorizeCtx(context.Context, oso.Value, string, oso.Value, *oso.AuthorizeOptions) (bool, error) {
+	return false, errors.New("unavailable")
+}
+
+func TestMiddlewareHandlers(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := Middleware(failingClient{newTestClient()}, currentUser, Constant(oso.NewValue("Repo", "acme")))(next)
+	if w := serve(handler, "GET", "/", "alice"); w.Code != 503 {
+		t.Fatalf("got %d, want %d", w.Code, 503)
+	}
+
+	var handled error
+	handler = Middleware(failingClient{newTestClient()}, currentUser, Constant(oso.NewValue("Repo", "acme")),
+		WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			handled = err
+			w.WriteHeader(http.StatusTeapot)
+		}))(next)
+	if w := serve(handler, "GET", "/", "alice"); w.Code != http.StatusTeapot || handled == nil {
+		t.Fatalf("got %d and %v, want %d and an error", w.Code, handled, http.StatusTeapot)
+	}
+
+	handler = Middleware(newTestClient(), currentUser, Constant(oso.NewValue("Repo", "acme")),
+		WithDeniedHandlers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "go away", http.StatusForbidden)
+		}), nil))(next)
+	if w := serve(handler, "GET", "/", "carol"); w.Code != 403 || !strings.Contains(w.Body.String(), "go away") {
+		t.Fatalf("got %d and %q, want %d and %q", w.Code, w.Body.String(), 403, "go away")
+	}
+	if w := serve(handler, "OPTIONS", "/", "alice"); w.Code != 400 {
+		t.Fatalf("got %d for an unmapped method, want %d", w.Code, 400)
+	}
+
+	handler = Middleware(newTestClient(), currentUser, Constant(oso.Value{Type: "Repo"}))(next)
+	if w := serve(handler, "GET", "/", "alice"); w.Code != 400 {
+		t.Fatalf("got %d for an invalid resource, want %d", w.Code, 400)
+	}
+
+	handled = nil
+	handler = Middleware(newTestClient(), currentUser, Constant(oso.NewValue("Repo", "acme")),
+		WithUnauthorizedHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			handled = err
+			http.Redirect(w, r, "/login", http.StatusFound)
+		}))(next)
+	if w := serve(handler, "GET", "/", ""); w.Code != http.StatusFound || handled == nil {
+		t.Fatalf("got %d and %v without an actor, want %d and an error", w.Code, handled, http.StatusFound)
+	}
+	handler = Middleware(newTestClient(), Constant(oso.Value{}), Constant(oso.NewValue("Repo", "acme")))(next)
+	if w := serve(handler, "GET", "/", "alice"); w.Code != 401 {
+		t.Fatalf("got %d for an empty actor, want %d", w.Code, 401)
+	}
+}
+
+func TestMiddlewareContextFacts(t *testing.T) {
+	client := newTestClient()
+	client.AddRule(func(facts osotest.Facts, actor oso.Value, action string, resource oso.Value) bool {
+		return action == "read" && facts.Has(oso.NewFact("is_internal_network", resource))
+	})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := Middleware(client, currentUser, Constant(oso.NewValue("Repo", "acme")),
+		WithContextFacts(func(r *http.Request) ([]oso.Fact, error) {
+			if r.Header.Get("X-Internal") == "" {
+				return nil, nil
+			}
+			return []oso.Fact{oso.NewFact("is_internal_network", oso.NewValue("Repo", "acme"))}, nil
+		}))(next)
+
+	if w := serve(handler, "GET", "/", "carol"); w.Code != 403 {
+		t.Fatalf("got %d, want %d", w.Code, 403)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-User", "carol")
+	r.Header.Set("X-Internal", "1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Fatalf("got %d with context facts, want %d", w.Code, 200)
+	}
+}
+
+func TestLoadActions(t *testing.T) {
+	var actions []string
+	var canDelete bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actions, _ = ActionsFromContext(r.Context())
+		canDelete = Can(r.Context(), "delete")
+	})
+	handler := LoadActions(newTestClient(), currentUser, PathValue("Repo", "/repos/"))(next)
+
+	if w := serve(handler, "GET", "/repos/acme", "bob"); w.Code != 200 || !reflect.DeepEqual(actions, []string{"read"}) || canDelete {
+		t.Fatalf("got %d, %v and %t, want %d, %v and %t", w.Code, actions, canDelete, 200, []string{"read"}, false)
+	}
+	if w := serve(handler, "GET", "/repos/acme", "alice"); w.Code != 200 || !canDelete {
+		t.Fatalf("got %d and %t, want %d and %t", w.Code, canDelete, 200, true)
+	}
+}
@@ -0,0 +1,156 @@
+// Package osoprom exports metrics about an Oso Cloud client to Prometheus.
+//
+//	recorder := osoprom.NewRecorder()
+//	prometheus.MustRegister(recorder)
+//
+//	client, err := oso.New(url, apiKey, oso.WithMetricsRecorder(recorder))
+//
+// To alert when the fallback node is serving traffic, use eg.
+//
+//	rate(oso_client_fallback_requests_total[5m]) > 0
+//
+// It's a separate module, so that only programs which use it depend on the
+// Prometheus client.
+package osoprom
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Recorder is an [oso.MetricsRecorder] which is also a [prometheus.Collector].
+// Register it with a Prometheus registry to export the following metrics:
+//
+//   - oso_client_request_duration_seconds: histogram of call latency, by endpoint
+//   - oso_client_requests_total: calls, by endpoint and status code
+//   - oso_client_request_errors_total: failed calls, by endpoint and error class
+//   - oso_client_retries_total: retries, by endpoint
+//   - oso_client_fallback_requests_total: calls sent to the fallback URL, by endpoint
+//   - oso_client_request_size_bytes: histogram of request body sizes, by endpoint
+//   - oso_client_response_size_bytes: histogram of response body sizes, by endpoint
+//   - oso_client_decisions_total: authorization decisions, by action and result
+//   - oso_client_degraded_decisions_total: decisions made by the client's
+//     error policy, by action and result
+type Recorder struct {
+	duration  *prometheus.HistogramVec
+	requests  *prometheus.CounterVec
+	errors    *prometheus.CounterVec
+	retries   *prometheus.CounterVec
+	fallbacks *prometheus.CounterVec
+	reqSize   *prometheus.HistogramVec
+	resSize   *prometheus.HistogramVec
+	decisions *prometheus.CounterVec
+	degraded  *prometheus.CounterVec
+}
+
+var _ oso.MetricsRecorder = (*Recorder)(nil)
+var _ prometheus.Collector = (*Recorder)(nil)
+
+// Create a recorder. Register it with a Prometheus registry to export its
+// metrics.
+func NewRecorder() *Recorder {
+	sizeBuckets := prometheus.ExponentialBuckets(64, 4, 10)
+	return &Recorder{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "oso_client_request_duration_seconds",
+			Help:    "Latency of calls to Oso Cloud, including retries and fallback.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oso_client_requests_total",
+			Help: "Calls to Oso Cloud, by final status code (0 if there was no response).",
+		}, []string{"endpoint", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oso_client_request_errors_total",
+			Help: "Failed calls to Oso Cloud, by class: 4xx, 5xx, canceled or network.",
+		}, []string{"endpoint", "class"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oso_client_retries_total",
+			Help: "Retried requests to Oso Cloud.",
+		}, []string{"endpoint"}),
+		fallbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oso_client_fallback_requests_total",
+			Help: "Calls that were sent to the fallback URL.",
+		}, []string{"endpoint"}),
+		reqSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "oso_client_request_size_bytes",
+			Help:    "Size of request bodies sent to Oso Cloud.",
+			Buckets: sizeBuckets,
+		}, []string{"endpoint"}),
+		resSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "oso_client_response_size_bytes",
+			Help:    "Size of response bodies received from Oso Cloud.",
+			Buckets: sizeBuckets,
+		}, []string{"endpoint"}),
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oso_client_decisions_total",
+			Help: "Authorization decisions, by action and result (allow or deny).",
+		}, []string{"action", "result"}),
+		degraded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oso_client_degraded_decisions_total",
+			Help: "Authorization decisions made by the error policy because Oso Cloud couldn't answer.",
+		}, []string{"action", "result"}),
+	}
+}
+
+func (r *Recorder) collectors() []prometheus.Collector {
+	return []prometheus.Collector{r.duration, r.requests, r.errors, r.retries, r.fallbacks, r.reqSize, r.resSize, r.decisions, r.degraded}
+}
+
+func (r *Recorder) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range r.collectors() {
+		c.Describe(ch)
+	}
+}
+
+func (r *Recorder) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range r.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (r *Recorder) ObserveRequest(m oso.RequestMetrics) {
+	r.duration.WithLabelValues(m.Endpoint).Observe(m.Duration.Seconds())
+	r.requests.WithLabelValues(m.Endpoint, strconv.Itoa(m.StatusCode)).Inc()
+	if class := errorClass(m); class != "" {
+		r.errors.WithLabelValues(m.Endpoint, class).Inc()
+	}
+	if m.Retries > 0 {
+		r.retries.WithLabelValues(m.Endpoint).Add(float64(m.Retries))
+	}
+	if m.Fallback {
+		r.fallbacks.WithLabelValues(m.Endpoint).Inc()
+	}
+	r.reqSize.WithLabelValues(m.Endpoint).Observe(float64(m.RequestBytes))
+	r.resSize.WithLabelValues(m.Endpoint).Observe(float64(m.ResponseBytes))
+}
+
+func (r *Recorder) ObserveDecision(m oso.DecisionMetrics) {
+	result := "deny"
+	if m.Allowed {
+		result = "allow"
+	}
+	r.decisions.WithLabelValues(m.Action, result).Inc()
+	if m.Degraded {
+		r.degraded.WithLabelValues(m.Action, result).Inc()
+	}
+}
+
+func errorClass(m oso.RequestMetrics) string {
+	switch {
+	case m.Err == nil:
+		return ""
+	case m.StatusCode >= 500:
+		return "5xx"
+	case m.StatusCode >= 400:
+		return "4xx"
+	case errors.Is(m.Err, context.Canceled) || errors.Is(m.Err, context.DeadlineExceeded):
+		return "canceled"
+	default:
+		return "network"
+	}
+}
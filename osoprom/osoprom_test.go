@@ -0,0 +1,65 @@
+package osoprom
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(recorder)
+
+	recorder.ObserveRequest(oso.RequestMetrics{Endpoint: "/authorize", Method: "POST", StatusCode: 200, Duration: time.Millisecond, Retries: 2, Fallback: true, RequestBytes: 100, ResponseBytes: 20})
+	recorder.ObserveRequest(oso.RequestMetrics{Endpoint: "/authorize", Method: "POST", StatusCode: 503, Err: errors.New("unavailable")})
+	recorder.ObserveRequest(oso.RequestMetrics{Endpoint: "/list", Method: "POST", Err: context.DeadlineExceeded})
+	recorder.ObserveDecision(oso.DecisionMetrics{Action: "read", Allowed: true})
+	recorder.ObserveDecision(oso.DecisionMetrics{Action: "read", Allowed: false})
+	recorder.ObserveDecision(oso.DecisionMetrics{Action: "read", Allowed: true, Degraded: true})
+
+	expected := `
+# HELP oso_client_decisions_total Authorization decisions, by action and result (allow or deny).
+# TYPE oso_client_decisions_total counter
+oso_client_decisions_total{action="read",result="allow"} 2
+oso_client_decisions_total{action="read",result="deny"} 1
+# HELP oso_client_degraded_decisions_total Authorization decisions made by the error policy because Oso Cloud couldn't answer.
+# TYPE oso_client_degraded_decisions_total counter
+oso_client_degraded_decisions_total{action="read",result="allow"} 1
+# HELP oso_client_fallback_requests_total Calls that were sent to the fallback URL.
+# TYPE oso_client_fallback_requests_total counter
+oso_client_fallback_requests_total{endpoint="/authorize"} 1
+# HELP oso_client_request_errors_total Failed calls to Oso Cloud, by class: 4xx, 5xx, canceled or network.
+# TYPE oso_client_request_errors_total counter
+oso_client_request_errors_total{class="5xx",endpoint="/authorize"} 1
+oso_client_request_errors_total{class="canceled",endpoint="/list"} 1
+# HELP oso_client_requests_total Calls to Oso Cloud, by final status code (0 if there was no response).
+# TYPE oso_client_requests_total counter
+oso_client_requests_total{code="0",endpoint="/list"} 1
+oso_client_requests_total{code="200",endpoint="/authorize"} 1
+oso_client_requests_total{code="503",endpoint="/authorize"} 1
+# HELP oso_client_retries_total Retried requests to Oso Cloud.
+# TYPE oso_client_retries_total counter
+oso_client_retries_total{endpoint="/authorize"} 2
+`
+	names := []string{
+		"oso_client_decisions_total",
+		"oso_client_degraded_decisions_total",
+		"oso_client_fallback_requests_total",
+		"oso_client_request_errors_total",
+		"oso_client_requests_total",
+		"oso_client_retries_total",
+	}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), names...); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(recorder, "oso_client_request_duration_seconds"); n != 2 {
+		t.Fatalf("got %d duration series, want 2", n)
+	}
+}
@@ -0,0 +1,175 @@
+// Package ososql runs local authorization queries against a database/sql
+// database, and decodes their results:
+//
+//	allowed, err := ososql.Authorize(ctx, db, oso.PlaceholderDollar, client, alice, "read", repo, nil)
+//	actions, err := ososql.Actions(ctx, db, oso.PlaceholderDollar, client, alice, repo, nil)
+//
+// Each function fetches the SQL from Oso Cloud, runs it with the given
+// [Queryer], which may be a *sql.DB, *sql.Tx or *sql.Conn, and scans the rows.
+// The SQL's literals are bound as arguments (see [oso.Parameterize]), written
+// with the placeholders of the database's driver. To use pgx, open a *sql.DB
+// with its stdlib package.
+//
+// See https://www.osohq.com/docs/authorization-data/local-authorization
+package ososql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+)
+
+// A Queryer runs SQL queries. *sql.DB, *sql.Tx and *sql.Conn implement it.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Determines whether actor may perform action on resource, by running the query
+// returned by [oso.OsoClientCtx.AuthorizeLocalParamsCtx].
+func Authorize(ctx context.Context, db Queryer, placeholder oso.Placeholder, client oso.OsoClientCtx, actor oso.Value, action string, resource oso.Value, options *oso.AuthorizeOptions) (bool, error) {
+	query, args, err := client.AuthorizeLocalParamsCtx(ctx, actor, action, resource, options, placeholder)
+	if err != nil {
+		return false, err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return false, err
+		}
+		return false, errors.New("ososql: authorization query returned no rows")
+	}
+	var allowed bool
+	if err := rows.Scan(&allowed); err != nil {
+		return false, err
+	}
+	return allowed, rows.Close()
+}
+
+// Returns the actions actor may perform on resource, by running the query
+// returned by [oso.OsoClientCtx.ActionsLocalParamsCtx].
+func Actions(ctx context.Context, db Queryer, placeholder oso.Placeholder, client oso.OsoClientCtx, actor oso.Value, resource oso.Value, contextFacts []oso.Fact) ([]string, error) {
+	query, args, err := client.ActionsLocalParamsCtx(ctx, actor, resource, contextFacts, placeholder)
+	if err != nil {
+		return nil, err
+	}
+	actions := []string{}
+	if err := scan(ctx, db, query, args, &actions); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// Runs the query returned by [oso.QueryBuilder.EvaluateLocalSelectParams] and
+// appends its rows to dest, which must be a pointer to a slice.
+//
+// If the slice's elements are structs, each column is stored in the field
+// tagged with its name, eg. `db:"user_id"`, or else in the exported field
+// whose name matches it, ignoring case and underscores. Otherwise, the query
+// must select a single column, which is stored in the elements directly:
+//
+//	type Member struct {
+//		UserID string `db:"user_id"`
+//		Role   string `db:"role"`
+//	}
+//	var members []Member
+//	err := ososql.Select(ctx, db, oso.PlaceholderDollar, query, map[string]oso.Variable{"user_id": user, "role": role}, &members)
+func Select(ctx context.Context, db Queryer, placeholder oso.Placeholder, query oso.QueryBuilder, columnNamesToQueryVars map[string]oso.Variable, dest interface{}) error {
+	selectQuery, args, err := query.WithCtx(ctx).EvaluateLocalSelectParams(columnNamesToQueryVars, placeholder)
+	if err != nil {
+		return err
+	}
+	return scan(ctx, db, selectQuery, args, dest)
+}
+
+// Runs query with args and appends its rows to dest, a pointer to a slice.
+func scan(ctx context.Context, db Queryer, query string, args []interface{}, dest interface{}) error {
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("ososql: dest must be a pointer to a slice, not %T", dest)
+	}
+	slice = slice.Elem()
+	elemType := slice.Type().Elem()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	fields, err := fieldsFor(elemType, columns)
+	if err != nil {
+		return err
+	}
+
+	targets := make([]interface{}, len(columns))
+	for rows.Next() {
+		elem := reflect.New(elemType).Elem()
+		for i := range columns {
+			if fields == nil {
+				targets[i] = elem.Addr().Interface()
+			} else {
+				targets[i] = elem.FieldByIndex(fields[i]).Addr().Interface()
+			}
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Returns the index of the field of each column, or nil if elemType isn't a
+// struct and is scanned into directly. Structs that implement sql.Scanner,
+// like sql.NullString, are scanned into directly too.
+func fieldsFor(elemType reflect.Type, columns []string) ([][]int, error) {
+	if elemType.Kind() != reflect.Struct || reflect.PtrTo(elemType).Implements(scannerType) {
+		if len(columns) != 1 {
+			return nil, fmt.Errorf("ososql: can't scan %d columns into %s", len(columns), elemType)
+		}
+		return nil, nil
+	}
+	fields := make([][]int, len(columns))
+	for i, column := range columns {
+		field, ok := fieldFor(elemType, column)
+		if !ok {
+			return nil, fmt.Errorf("ososql: %s has no field for column %q", elemType, column)
+		}
+		fields[i] = field.Index
+	}
+	return fields, nil
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+func fieldFor(structType reflect.Type, column string) (reflect.StructField, bool) {
+	if field, ok := structType.FieldByNameFunc(func(name string) bool {
+		field, _ := structType.FieldByName(name)
+		return field.PkgPath == "" && field.Tag.Get("db") == column
+	}); ok {
+		return field, true
+	}
+	return structType.FieldByNameFunc(func(name string) bool {
+		field, _ := structType.FieldByName(name)
+		return field.PkgPath == "" && field.Tag.Get("db") == "" && normalize(name) == normalize(column)
+	})
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
@@ -0,0 +1,203 @@
+package ososql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+)
+
+// A database/sql driver whose queries return canned results, keyed by SQL.
+// Queries must bind the expected arguments.
+type fakeDriver struct{}
+
+type fakeResult struct {
+	args    []driver.Value
+	columns []string
+	rows    [][]driver.Value
+}
+
+var fakeResults = map[string]fakeResult{
+	"SELECT true AS allowed WHERE actor = $1 AND n = $2": {
+		[]driver.Value{"alice", int64(1)}, []string{"allowed"}, [][]driver.Value{{true}},
+	},
+	"SELECT false AS allowed WHERE actor = $1 AND n = $2": {
+		[]driver.Value{"alice", int64(1)}, []string{"allowed"}, [][]driver.Value{{false}},
+	},
+	"SELECT actions WHERE resource = $1": {
+		[]driver.Value{"acme"}, []string{"actions"}, [][]driver.Value{{"read"}, {"write"}},
+	},
+	"SELECT user_id, role WHERE repo = $1": {
+		[]driver.Value{"acme"}, []string{"user_id", "role"}, [][]driver.Value{{"alice", "admin"}, {"bob", "member"}},
+	},
+}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	result, ok := fakeResults[query]
+	if !ok {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	return fakeStmt{result}, nil
+}
+
+func (fakeConn) Close() error              { return nil }
+func (fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	result fakeResult
+}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !reflect.DeepEqual(args, s.result.args) {
+		return nil, fmt.Errorf("got arguments %#v, want %#v", args, s.result.args)
+	}
+	return &fakeRows{result: s.result}, nil
+}
+
+type fakeRows struct {
+	result fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+func init() {
+	sql.Register("ososql-fake", fakeDriver{})
+}
+
+// Returns a client whose local authorization endpoints return the SQL of the
+// fake driver.
+func newTestClient(t *testing.T) oso.OsoClientCtx {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query struct {
+				Action string `json:"action"`
+			} `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		var query string
+		switch r.URL.Path {
+		case "/api/authorize_query":
+			query = "SELECT false AS allowed WHERE actor = 'alice' AND n = 1"
+			if body.Query.Action == "read" {
+				query = "SELECT true AS allowed WHERE actor = 'alice' AND n = 1"
+			}
+		case "/api/actions_query":
+			query = "SELECT actions WHERE resource = 'acme'"
+		case "/api/evaluate_query_local":
+			query = "SELECT user_id, role WHERE repo = 'acme'"
+		}
+		json.NewEncoder(w).Encode(map[string]string{"sql": query})
+	}))
+	t.Cleanup(server.Close)
+	client, err := oso.New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", oso.WithRetryPolicy(oso.RetryPolicy{MaxRetries: 0}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return client
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("ososql-fake", "")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestAuthorize(t *testing.T) {
+	client := newTestClient(t)
+	db := openDB(t)
+	ctx := context.Background()
+	alice := oso.NewValue("User", "alice")
+	repo := oso.NewValue("Repo", "acme")
+
+	for action, expected := range map[string]bool{"read": true, "delete": false} {
+		allowed, err := Authorize(ctx, db, oso.PlaceholderDollar, client, alice, action, repo, nil)
+		if err != nil || allowed != expected {
+			t.Errorf("Authorize(%s) = %t, %v, want %t", action, allowed, err, expected)
+		}
+	}
+
+	if _, err := Authorize(ctx, db, oso.PlaceholderDollar, client, oso.Value{}, "read", repo, nil); err == nil {
+		t.Errorf("Authorize with an invalid actor succeeded, want error")
+	}
+}
+
+func TestActions(t *testing.T) {
+	client := newTestClient(t)
+	db := openDB(t)
+	actions, err := Actions(context.Background(), db, oso.PlaceholderDollar, client, oso.NewValue("User", "alice"), oso.NewValue("Repo", "acme"), nil)
+	if err != nil || !reflect.DeepEqual(actions, []string{"read", "write"}) {
+		t.Fatalf("got %q, %v, want [read write]", actions, err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	client := newTestClient(t)
+	db := openDB(t)
+	ctx := context.Background()
+	user, role := oso.TypedVar("User"), oso.TypedVar("String")
+	query := client.BuildQuery(oso.NewQueryFact("has_role", user, role, oso.NewValue("Repo", "acme")))
+	columns := map[string]oso.Variable{"user_id": user, "role": role}
+
+	type Member struct {
+		User string `db:"user_id"`
+		Role string
+	}
+	var members []Member
+	if err := Select(ctx, db, oso.PlaceholderDollar, query, columns, &members); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	expected := []Member{{"alice", "admin"}, {"bob", "member"}}
+	if !reflect.DeepEqual(members, expected) {
+		t.Fatalf("got %v, want %v", members, expected)
+	}
+
+	var users []string
+	if err := Select(ctx, db, oso.PlaceholderDollar, query, columns, &users); err == nil || !strings.Contains(err.Error(), "can't scan 2 columns") {
+		t.Fatalf("got %v, want a column count error", err)
+	}
+	type Unmatched struct {
+		User string `db:"user_id"`
+	}
+	if err := Select(ctx, db, oso.PlaceholderDollar, query, columns, &[]Unmatched{}); err == nil || !strings.Contains(err.Error(), `no field for column "role"`) {
+		t.Fatalf("got %v, want a missing field error", err)
+	}
+	if err := Select(ctx, db, oso.PlaceholderDollar, query, columns, members); err == nil {
+		t.Fatalf("Select into a slice succeeded, want error")
+	}
+}
@@ -0,0 +1,592 @@
+// Package osotest provides an in-memory implementation of [oso.OsoClientCtx] for
+// unit tests, so that code which checks permissions can be tested without an
+// Oso Cloud environment.
+//
+// The fake does not evaluate Polar. Instead, tests describe authorization logic
+// in Go, either as role/permission tables or as arbitrary [Rule] functions:
+//
+//	client := osotest.NewClient()
+//	client.AddRole("Repo", "member", "read")
+//	client.AddRule(func(facts osotest.Facts, actor oso.Value, action string, resource oso.Value) bool {
+//		return action == "read" && facts.Has(oso.NewFact("is_public", resource))
+//	})
+//	client.Insert(oso.NewFact("has_role", alice, oso.String("member"), acme))
+//
+//	allowed, err := client.Authorize(alice, "read", acme) // true
+package osotest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"github.com/osohq/go-oso-cloud/v2/internal/backend"
+)
+
+// Returned by the *Local methods, which need Oso Cloud to generate SQL.
+var ErrLocalNotSupported = errors.New("osotest: local authorization is not supported")
+
+// Returned when a [oso.ParityHandle] is passed to the fake, which has no
+// request IDs to report.
+var ErrParityHandleNotSupported = errors.New("osotest: ParityHandle is not supported")
+
+// A Rule decides whether actor may perform action on resource, given the
+// facts visible to the decision. An action is allowed if any rule allows it.
+type Rule func(facts Facts, actor oso.Value, action string, resource oso.Value) bool
+
+// Client is an in-memory [oso.OsoClientCtx]. The zero value is not usable; create
+// one with [NewClient]. A Client is safe for concurrent use.
+type Client struct {
+	mu      sync.RWMutex
+	facts   []oso.Fact
+	rules   []Rule
+	roles   map[string]map[string][]string // resource type -> role -> permissions
+	actions map[string][]string            // resource type -> actions
+	policy  string
+	writes  uint64
+}
+
+var _ oso.OsoClientCtx = (*Client)(nil)
+
+// Create an empty in-memory client, with no facts and no rules.
+func NewClient() *Client {
+	return &Client{
+		roles:   make(map[string]map[string][]string),
+		actions: make(map[string][]string),
+	}
+}
+
+// Register a rule which is consulted by Authorize, List, Actions and BuildQuery.
+//
+// Actions and BuildQuery can only report actions that have been declared with
+// [Client.DeclareActions] or [Client.AddRole], since rules can't be enumerated.
+func (c *Client) AddRule(rule Rule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = append(c.rules, rule)
+}
+
+// Grant the given permissions to actors which have the given role on a
+// resource of the given type, ie. for which a fact like
+//
+//	has_role(actor, String(role), resource)
+//
+// exists. The permissions are also declared as actions of the resource type.
+func (c *Client) AddRole(resourceType string, role string, permissions ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.roles[resourceType] == nil {
+		c.roles[resourceType] = make(map[string][]string)
+	}
+	c.roles[resourceType][role] = append(c.roles[resourceType][role], permissions...)
+	c.declareActions(resourceType, permissions)
+}
+
+// Declare the actions which can be performed on resources of the given type.
+// Actions and BuildQuery only consider declared actions.
+func (c *Client) DeclareActions(resourceType string, actions ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.declareActions(resourceType, actions)
+}
+
+func (c *Client) declareActions(resourceType string, actions []string) {
+	for _, action := range actions {
+		if !containsString(c.actions[resourceType], action) {
+			c.actions[resourceType] = append(c.actions[resourceType], action)
+		}
+	}
+}
+
+// Returns a copy of every fact stored in the client.
+func (c *Client) Facts() Facts {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append(Facts{}, c.facts...)
+}
+
+// Returns the policy most recently passed to Policy.
+func (c *Client) CurrentPolicy() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.policy
+}
+
+// Remove all facts, leaving rules and roles in place.
+func (c *Client) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.facts = nil
+}
+
+// The state a decision needs, copied under c.mu so that roles and rules can be
+// evaluated without holding it. Rules may then call back into the client.
+type snapshot struct {
+	facts   Facts // stored facts followed by context facts
+	rules   []Rule
+	roles   map[string]map[string][]string
+	actions map[string][]string
+}
+
+// Copies the client's state, adding the context facts to the stored ones.
+// Permission and action slices are shared: they are only ever appended to, so
+// the snapshot's view of them doesn't change.
+func (c *Client) snapshot(contextFacts []oso.Fact) *snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	facts := make(Facts, 0, len(c.facts)+len(contextFacts))
+	facts = append(facts, c.facts...)
+	s := &snapshot{
+		facts:   append(facts, contextFacts...),
+		rules:   append([]Rule{}, c.rules...),
+		roles:   make(map[string]map[string][]string, len(c.roles)),
+		actions: make(map[string][]string, len(c.actions)),
+	}
+	for resourceType, roles := range c.roles {
+		s.roles[resourceType] = make(map[string][]string, len(roles))
+		for role, permissions := range roles {
+			s.roles[resourceType][role] = permissions
+		}
+	}
+	for resourceType, actions := range c.actions {
+		s.actions[resourceType] = actions
+	}
+	return s
+}
+
+func (s *snapshot) allowed(actor oso.Value, action string, resource oso.Value) bool {
+	for role, permissions := range s.roles[resource.Type] {
+		if containsString(permissions, action) && s.facts.Has(oso.NewFact("has_role", actor, oso.String(role), resource)) {
+			return true
+		}
+	}
+	for _, rule := range s.rules {
+		if rule(s.facts, actor, action, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) insert(fact oso.Fact) {
+	if !Facts(c.facts).Has(fact) {
+		c.facts = append(c.facts, fact)
+	}
+}
+
+func (c *Client) delete(pattern oso.IntoFactPattern) {
+	kept := c.facts[:0]
+	for _, fact := range c.facts {
+		if !matchPattern(pattern, fact) {
+			kept = append(kept, fact)
+		}
+	}
+	c.facts = kept
+}
+
+// Records a write, returning its token. Must be called with c.mu held.
+func (c *Client) commit() oso.ConsistencyToken {
+	c.writes++
+	return oso.ConsistencyToken(strconv.FormatUint(c.writes, 10))
+}
+
+// Returns the token of the latest write. The fake is always consistent, so
+// tokens passed to reads with [oso.WithConsistencyToken] are ignored.
+func (c *Client) ConsistencyToken() oso.ConsistencyToken {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.writes == 0 {
+		return ""
+	}
+	return oso.ConsistencyToken(strconv.FormatUint(c.writes, 10))
+}
+
+// Always reports a healthy primary endpoint, unless ctx is done.
+func (c *Client) Health(ctx context.Context) *oso.HealthReport {
+	health := oso.EndpointHealth{URL: "osotest", Reachable: true, Authorized: true, StatusCode: 200, Err: ctx.Err()}
+	return &oso.HealthReport{Primary: health, Circuit: oso.CircuitClosed}
+}
+
+func (c *Client) Insert(fact oso.Fact) error {
+	return c.InsertCtx(context.Background(), fact)
+}
+
+func (c *Client) InsertCtx(ctx context.Context, fact oso.Fact) error {
+	_, err := c.InsertWithToken(ctx, fact)
+	return err
+}
+
+func (c *Client) InsertWithToken(ctx context.Context, fact oso.Fact) (oso.ConsistencyToken, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := validateFact(fact); err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.insert(fact)
+	return c.commit(), nil
+}
+
+func (c *Client) Delete(pattern oso.IntoFactPattern) error {
+	return c.DeleteCtx(context.Background(), pattern)
+}
+
+func (c *Client) DeleteCtx(ctx context.Context, pattern oso.IntoFactPattern) error {
+	_, err := c.DeleteWithToken(ctx, pattern)
+	return err
+}
+
+func (c *Client) DeleteWithToken(ctx context.Context, pattern oso.IntoFactPattern) (oso.ConsistencyToken, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := validatePattern(pattern); err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delete(pattern)
+	return c.commit(), nil
+}
+
+func (c *Client) Batch(fn func(tx oso.BatchTransaction)) error {
+	return c.BatchCtx(context.Background(), fn)
+}
+
+// Applies all of the inserts and deletes in order, atomically.
+func (c *Client) BatchCtx(ctx context.Context, fn func(tx oso.BatchTransaction)) error {
+	_, err := c.BatchWithToken(ctx, fn)
+	return err
+}
+
+func (c *Client) BatchWithToken(ctx context.Context, fn func(tx oso.BatchTransaction)) (oso.ConsistencyToken, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	operations, err := backend.RecordBatch(fn)
+	if err != nil {
+		return "", err
+	}
+	for _, op := range operations {
+		if op.Delete != nil {
+			if err := validatePattern(op.Delete.(oso.IntoFactPattern)); err != nil {
+				return "", err
+			}
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, op := range operations {
+		if op.Insert != nil {
+			c.insert(*op.Insert.(*oso.Fact))
+		} else {
+			c.delete(op.Delete.(oso.IntoFactPattern))
+		}
+	}
+	return c.commit(), nil
+}
+
+func (c *Client) BatchChunked(fn func(tx oso.BatchTransaction)) (oso.BatchResult, error) {
+	return c.BatchChunkedCtx(context.Background(), fn)
+}
+
+// Applies the batch as a single chunk, since there is no request size limit
+// to stay under.
+func (c *Client) BatchChunkedCtx(ctx context.Context, fn func(tx oso.BatchTransaction)) (oso.BatchResult, error) {
+	if err := c.BatchCtx(ctx, fn); err != nil {
+		return oso.BatchResult{}, err
+	}
+	return oso.BatchResult{ChunksCommitted: 1, ChunksTotal: 1}, nil
+}
+
+func (c *Client) Get(pattern oso.IntoFactPattern) ([]oso.Fact, error) {
+	return c.GetCtx(context.Background(), pattern)
+}
+
+func (c *Client) GetCtx(ctx context.Context, pattern oso.IntoFactPattern) ([]oso.Fact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := validatePattern(pattern); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Facts(c.facts).Match(pattern), nil
+}
+
+func (c *Client) GetIter(pattern oso.IntoFactPattern, options *oso.GetIterOptions) (*oso.FactIterator, error) {
+	return c.GetIterCtx(context.Background(), pattern, options)
+}
+
+// Returns an iterator over a snapshot of the matching facts.
+func (c *Client) GetIterCtx(ctx context.Context, pattern oso.IntoFactPattern, options *oso.GetIterOptions) (*oso.FactIterator, error) {
+	facts, err := c.GetCtx(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return backend.NewFactIterator(facts).(*oso.FactIterator), nil
+}
+
+// Stores the policy, which can be read back with [Client.CurrentPolicy]. The
+// policy is not evaluated.
+func (c *Client) Policy(policy string) error {
+	return c.PolicyCtx(context.Background(), policy)
+}
+
+func (c *Client) PolicyCtx(ctx context.Context, policy string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policy = policy
+	return nil
+}
+
+// Describes the resource types, roles and permissions registered with
+// [Client.AddRole] and [Client.DeclareActions].
+func (c *Client) GetPolicyMetadata() (*oso.PolicyMetadata, error) {
+	return c.GetPolicyMetadataCtx(context.Background())
+}
+
+func (c *Client) GetPolicyMetadataCtx(ctx context.Context) (*oso.PolicyMetadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	resources := make(map[string]oso.ResourceMetadata)
+	for resourceType, actions := range c.actions {
+		roles := []string{}
+		for role := range c.roles[resourceType] {
+			roles = append(roles, role)
+		}
+		sort.Strings(roles)
+		resources[resourceType] = oso.ResourceMetadata{
+			Permissions: append([]string{}, actions...),
+			Roles:       roles,
+			Relations:   map[string]string{},
+		}
+	}
+	return &oso.PolicyMetadata{Resources: resources}, nil
+}
+
+func (c *Client) Actions(actor oso.Value, resource oso.Value) ([]string, error) {
+	return c.ActionsCtx(context.Background(), actor, resource, nil)
+}
+
+func (c *Client) ActionsWithContext(actor oso.Value, resource oso.Value, contextFacts []oso.Fact) ([]string, error) {
+	return c.ActionsCtx(context.Background(), actor, resource, contextFacts)
+}
+
+// Returns the declared actions of the resource's type which the actor is
+// allowed to perform.
+func (c *Client) ActionsCtx(ctx context.Context, actor oso.Value, resource oso.Value, contextFacts []oso.Fact) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := validateDecision(actor, resource, contextFacts); err != nil {
+		return nil, err
+	}
+	s := c.snapshot(contextFacts)
+	actions := []string{}
+	for _, action := range s.actions[resource.Type] {
+		if s.allowed(actor, action, resource) {
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+func (c *Client) Authorize(actor oso.Value, action string, resource oso.Value) (bool, error) {
+	return c.AuthorizeCtx(context.Background(), actor, action, resource, nil)
+}
+
+func (c *Client) AuthorizeWithContext(actor oso.Value, action string, resource oso.Value, contextFacts []oso.Fact) (bool, error) {
+	return c.AuthorizeCtx(context.Background(), actor, action, resource, &oso.AuthorizeOptions{ContextFacts: contextFacts})
+}
+
+func (c *Client) AuthorizeWithOptions(actor oso.Value, action string, resource oso.Value, options *oso.AuthorizeOptions) (bool, error) {
+	return c.AuthorizeCtx(context.Background(), actor, action, resource, options)
+}
+
+func (c *Client) AuthorizeCtx(ctx context.Context, actor oso.Value, action string, resource oso.Value, options *oso.AuthorizeOptions) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if options == nil {
+		options = &oso.AuthorizeOptions{}
+	}
+	if options.ParityHandle != nil {
+		return false, ErrParityHandleNotSupported
+	}
+	if options.Outcome != nil {
+		// The fake always answers, so its decisions are never degraded.
+		*options.Outcome = oso.AuthorizeOutcome{}
+	}
+	if err := validateDecision(actor, resource, options.ContextFacts); err != nil {
+		return false, err
+	}
+	return c.snapshot(options.ContextFacts).allowed(actor, action, resource), nil
+}
+
+func (c *Client) AuthorizeResources(actor oso.Value, action string, resources []oso.Value, options *oso.AuthorizeOptions) ([]oso.Value, error) {
+	return c.AuthorizeResourcesCtx(context.Background(), actor, action, resources, options)
+}
+
+func (c *Client) AuthorizeResourcesCtx(ctx context.Context, actor oso.Value, action string, resources []oso.Value, options *oso.AuthorizeOptions) ([]oso.Value, error) {
+	allowed, err := c.AuthorizeResourcesMapCtx(ctx, actor, action, resources, options)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]oso.Value, 0, len(allowed))
+	for _, resource := range resources {
+		if allowed[resource] {
+			results = append(results, resource)
+		}
+	}
+	return results, nil
+}
+
+func (c *Client) AuthorizeResourcesMap(actor oso.Value, action string, resources []oso.Value, options *oso.AuthorizeOptions) (map[oso.Value]bool, error) {
+	return c.AuthorizeResourcesMapCtx(context.Background(), actor, action, resources, options)
+}
+
+func (c *Client) AuthorizeResourcesMapCtx(ctx context.Context, actor oso.Value, action string, resources []oso.Value, options *oso.AuthorizeOptions) (map[oso.Value]bool, error) {
+	allowed := make(map[oso.Value]bool, len(resources))
+	for _, resource := range resources {
+		ok, err := c.AuthorizeCtx(ctx, actor, action, resource, options)
+		if err != nil {
+			return nil, err
+		}
+		allowed[resource] = ok
+	}
+	return allowed, nil
+}
+
+// Like the real client, List ignores the contextFacts argument; use
+// ListWithContext to pass context facts.
+func (c *Client) List(actor oso.Value, action string, resourceType string, contextFacts []oso.Fact) ([]string, error) {
+	return c.ListCtx(context.Background(), actor, action, resourceType, nil)
+}
+
+func (c *Client) ListWithContext(actor oso.Value, action string, resourceType string, contextFacts []oso.Fact) ([]string, error) {
+	return c.ListCtx(context.Background(), actor, action, resourceType, contextFacts)
+}
+
+// Returns the IDs of resources of the given type on which the actor can
+// perform the action. Only resources which appear in a stored or context fact
+// are considered.
+func (c *Client) ListCtx(ctx context.Context, actor oso.Value, action string, resourceType string, contextFacts []oso.Fact) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := validateDecision(actor, oso.NewValue(resourceType, "_"), contextFacts); err != nil {
+		return nil, err
+	}
+	s := c.snapshot(contextFacts)
+	results := []string{}
+	for _, id := range idsOfType(s.facts, resourceType) {
+		if s.allowed(actor, action, oso.NewValue(resourceType, id)) {
+			results = append(results, id)
+		}
+	}
+	return results, nil
+}
+
+func (c *Client) BuildQuery(query oso.QueryFact) oso.QueryBuilder {
+	return backend.NewQueryBuilder(query, c.evaluateQuery).(oso.QueryBuilder)
+}
+
+func (c *Client) AuthorizeLocal(actor oso.Value, action string, resource oso.Value) (string, error) {
+	return "", ErrLocalNotSupported
+}
+
+func (c *Client) AuthorizeLocalWithContext(actor oso.Value, action string, resource oso.Value, contextFacts []oso.Fact) (string, error) {
+	return "", ErrLocalNotSupported
+}
+
+func (c *Client) AuthorizeLocalWithOptions(actor oso.Value, action string, resource oso.Value, options *oso.AuthorizeOptions) (string, error) {
+	return "", ErrLocalNotSupported
+}
+
+func (c *Client) AuthorizeLocalCtx(ctx context.Context, actor oso.Value, action string, resource oso.Value, options *oso.AuthorizeOptions) (string, error) {
+	return "", ErrLocalNotSupported
+}
+
+func (c *Client) ListLocal(actor oso.Value, action string, resource string, column string) (string, error) {
+	return "", ErrLocalNotSupported
+}
+
+func (c *Client) ListLocalWithContext(actor oso.Value, action string, resource string, column string, contextFacts []oso.Fact) (string, error) {
+	return "", ErrLocalNotSupported
+}
+
+func (c *Client) ListLocalCtx(ctx context.Context, actor oso.Value, action string, resource string, column string, contextFacts []oso.Fact) (string, error) {
+	return "", ErrLocalNotSupported
+}
+
+func (c *Client) ActionsLocal(actor oso.Value, resource oso.Value) (string, error) {
+	return "", ErrLocalNotSupported
+}
+
+func (c *Client) ActionsLocalWithContext(actor oso.Value, resource oso.Value, contextFacts []oso.Fact) (string, error) {
+	return "", ErrLocalNotSupported
+}
+
+func (c *Client) ActionsLocalCtx(ctx context.Context, actor oso.Value, resource oso.Value, contextFacts []oso.Fact) (string, error) {
+	return "", ErrLocalNotSupported
+}
+
+func (c *Client) AuthorizeLocalParamsCtx(ctx context.Context, actor oso.Value, action string, resource oso.Value, options *oso.AuthorizeOptions, placeholder oso.Placeholder) (string, []interface{}, error) {
+	return "", nil, ErrLocalNotSupported
+}
+
+func (c *Client) ListLocalParamsCtx(ctx context.Context, actor oso.Value, action string, resource string, column string, contextFacts []oso.Fact, placeholder oso.Placeholder, offset int) (string, []interface{}, error) {
+	return "", nil, ErrLocalNotSupported
+}
+
+func (c *Client) ActionsLocalParamsCtx(ctx context.Context, actor oso.Value, resource oso.Value, contextFacts []oso.Fact, placeholder oso.Placeholder) (string, []interface{}, error) {
+	return "", nil, ErrLocalNotSupported
+}
+
+func validateDecision(actor oso.Value, resource oso.Value, contextFacts []oso.Fact) error {
+	if err := validateValue(actor); err != nil {
+		return err
+	}
+	if err := validateValue(resource); err != nil {
+		return err
+	}
+	return validateFacts(contextFacts)
+}
+
+// Returns the distinct IDs of all values of the given type, in the order in
+// which they first appear.
+func idsOfType(facts Facts, typ string) []string {
+	ids := []string{}
+	seen := make(map[string]bool)
+	for _, fact := range facts {
+		for _, arg := range fact.Args {
+			if arg.Type == typ && !seen[arg.ID] {
+				seen[arg.ID] = true
+				ids = append(ids, arg.ID)
+			}
+		}
+	}
+	return ids
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
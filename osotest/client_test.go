@@ -0,0 +1,244 @@
+package osotest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+)
+
+func setupClient() (*Client, oso.Value, oso.Value, oso.Value) {
+	client := NewClient()
+	client.AddRole("Repo", "member", "read")
+	client.AddRole("Repo", "owner", "read", "write")
+	client.DeclareActions("Repo", "archive")
+	client.AddRule(func(facts Facts, actor oso.Value, action string, resource oso.Value) bool {
+		return action == "read" && facts.Has(oso.NewFact("is_public", resource))
+	})
+
+	alice := oso.NewValue("User", "alice")
+	acme := oso.NewValue("Repo", "acme")
+	anvil := oso.NewValue("Repo", "anvil")
+	client.Insert(oso.NewFact("has_role", alice, oso.String("member"), acme))
+	client.Insert(oso.NewFact("has_role", alice, oso.String("owner"), anvil))
+	return client, alice, acme, anvil
+}
+
+func TestFacts(t *testing.T) {
+	client, alice, acme, anvil := setupClient()
+	bob := oso.NewValue("User", "bob")
+
+	// Inserting a fact twice stores it once.
+	client.Insert(oso.NewFact("has_role", alice, oso.String("member"), acme))
+	facts, err := client.Get(oso.NewFactPattern("has_role", alice, nil, oso.NewValueOfType("Repo")))
+	if err != nil || len(facts) != 2 {
+		t.Fatalf("Get = %v, %v, want 2 facts", facts, err)
+	}
+
+	err = client.Batch(func(tx oso.BatchTransaction) {
+		tx.Insert(oso.NewFact("has_role", bob, oso.String("member"), acme))
+		tx.Delete(oso.NewFactPattern("has_role", alice, nil, nil))
+		tx.Insert(oso.NewFact("has_role", alice, oso.String("member"), anvil))
+	})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	facts, _ = client.Get(oso.NewFactPattern("has_role", nil, nil, nil))
+	expected := []oso.Fact{
+		oso.NewFact("has_role", bob, oso.String("member"), acme),
+		oso.NewFact("has_role", alice, oso.String("member"), anvil),
+	}
+	if !reflect.DeepEqual(facts, expected) {
+		t.Fatalf("Get = %v, want %v", facts, expected)
+	}
+
+	// An invalid batch is not applied at all.
+	err = client.Batch(func(tx oso.BatchTransaction) {
+		tx.Delete(oso.NewFactPattern("has_role", nil, nil, nil))
+		tx.Insert(oso.NewFact("has_role", oso.NewValue("User", ""), oso.String("member"), acme))
+	})
+	if !errors.Is(err, oso.ErrInvalidValue) {
+		t.Fatalf("Batch error = %v, want ErrInvalidValue", err)
+	}
+	if facts := client.Facts(); len(facts) != 2 {
+		t.Fatalf("Facts = %v after failed batch, want 2 facts", facts)
+	}
+
+	if err := client.Delete(oso.NewFact("has_role", bob, oso.String("member"), acme)); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if facts := client.Facts(); !reflect.DeepEqual(facts, Facts(expected[1:])) {
+		t.Fatalf("Facts = %v, want %v", facts, expected[1:])
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	client, alice, acme, anvil := setupClient()
+	bob := oso.NewValue("User", "bob")
+
+	testCases := []struct {
+		actor    oso.Value
+		action   string
+		resource oso.Value
+		expected bool
+	}{
+		{alice, "read", acme, true},
+		{alice, "write", acme, false},
+		{alice, "write", anvil, true},
+		{bob, "read", acme, false},
+	}
+	for _, tc := range testCases {
+		allowed, err := client.Authorize(tc.actor, tc.action, tc.resource)
+		if err != nil || allowed != tc.expected {
+			t.Fatalf("Authorize(%v, %q, %v) = %t, %v, want %t", tc.actor, tc.action, tc.resource, allowed, err, tc.expected)
+		}
+	}
+
+	public := []oso.Fact{oso.NewFact("is_public", acme)}
+	allowed, err := client.AuthorizeWithContext(bob, "read", acme, public)
+	if err != nil || !allowed {
+		t.Fatalf("AuthorizeWithContext = %t, %v, want %t", allowed, err, true)
+	}
+
+	_, err = client.Authorize(oso.NewValue("", "alice"), "read", acme)
+	if !errors.Is(err, oso.ErrInvalidValue) {
+		t.Fatalf("Authorize error = %v, want ErrInvalidValue", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.AuthorizeCtx(ctx, alice, "read", acme, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("AuthorizeCtx error = %v, want %v", err, context.Canceled)
+	}
+
+	authorized, err := client.AuthorizeResources(alice, "write", []oso.Value{acme, anvil}, nil)
+	if err != nil || !reflect.DeepEqual(authorized, []oso.Value{anvil}) {
+		t.Fatalf("AuthorizeResources = %v, %v, want %v", authorized, err, []oso.Value{anvil})
+	}
+}
+
+func TestListAndActions(t *testing.T) {
+	client, alice, acme, anvil := setupClient()
+
+	repos, err := client.List(alice, "write", "Repo", nil)
+	if err != nil || !reflect.DeepEqual(repos, []string{"anvil"}) {
+		t.Fatalf("List = %v, %v, want %v", repos, err, []string{"anvil"})
+	}
+
+	public := []oso.Fact{oso.NewFact("is_public", oso.NewValue("Repo", "public"))}
+	repos, err = client.ListWithContext(alice, "read", "Repo", public)
+	if err != nil || !reflect.DeepEqual(repos, []string{"acme", "anvil", "public"}) {
+		t.Fatalf("ListWithContext = %v, %v, want %v", repos, err, []string{"acme", "anvil", "public"})
+	}
+
+	actions, err := client.Actions(alice, anvil)
+	if err != nil || !reflect.DeepEqual(actions, []string{"read", "write"}) {
+		t.Fatalf("Actions = %v, %v, want %v", actions, err, []string{"read", "write"})
+	}
+	actions, err = client.Actions(alice, acme)
+	if err != nil || !reflect.DeepEqual(actions, []string{"read"}) {
+		t.Fatalf("Actions = %v, %v, want %v", actions, err, []string{"read"})
+	}
+
+	metadata, err := client.GetPolicyMetadata()
+	expected := oso.ResourceMetadata{
+		Permissions: []string{"read", "write", "archive"},
+		Roles:       []string{"member", "owner"},
+		Relations:   map[string]string{},
+	}
+	if err != nil || !reflect.DeepEqual(metadata.Resources["Repo"], expected) {
+		t.Fatalf("GetPolicyMetadata = %+v, %v, want %+v", metadata, err, expected)
+	}
+
+	if _, err := client.ListLocal(alice, "read", "Repo", "id"); err != ErrLocalNotSupported {
+		t.Fatalf("ListLocal error = %v, want %v", err, ErrLocalNotSupported)
+	}
+}
+
+func TestBuildQuery(t *testing.T) {
+	client, alice, acme, _ := setupClient()
+	client.Insert(oso.NewFact("has_relation", acme, oso.String("folder"), oso.NewValue("Folder", "src")))
+
+	repo := oso.TypedVar("Repo")
+	repos, err := client.BuildQuery(oso.NewQueryFact("allow", alice, oso.String("write"), repo)).EvaluateValues(repo)
+	if err != nil || !reflect.DeepEqual(repos, []string{"anvil"}) {
+		t.Fatalf("EvaluateValues = %v, %v, want %v", repos, err, []string{"anvil"})
+	}
+
+	action := oso.TypedVar("String")
+	var mapping map[string][]string
+	err = client.BuildQuery(oso.NewQueryFact("allow", alice, action, repo)).
+		Evaluate(&mapping, map[oso.Variable]oso.Variable{repo: action})
+	for _, actions := range mapping {
+		sort.Strings(actions)
+	}
+	expected := map[string][]string{"acme": {"read"}, "anvil": {"read", "write"}}
+	if err != nil || !reflect.DeepEqual(mapping, expected) {
+		t.Fatalf("Evaluate = %v, %v, want %v", mapping, err, expected)
+	}
+
+	repos, err = client.BuildQuery(oso.NewQueryFact("allow", alice, oso.String("read"), repo)).
+		And(oso.NewQueryFact("has_relation", repo, oso.String("folder"), oso.NewValue("Folder", "src"))).
+		EvaluateValues(repo)
+	if err != nil || !reflect.DeepEqual(repos, []string{"acme"}) {
+		t.Fatalf("EvaluateValues = %v, %v, want %v", repos, err, []string{"acme"})
+	}
+
+	exists, err := client.BuildQuery(oso.NewQueryFact("allow", alice, oso.String("read"), repo)).
+		In(repo, []string{"nonexistent"}).
+		EvaluateExists()
+	if err != nil || exists {
+		t.Fatalf("EvaluateExists = %t, %v, want %t", exists, err, false)
+	}
+
+	if _, err := client.BuildQuery(oso.NewQueryFact("allow", alice, oso.String("read"), repo)).EvaluateLocalFilter("id", repo); err == nil {
+		t.Fatalf("EvaluateLocalFilter succeeded, want error")
+	}
+}
+
+func TestConsistencyToken(t *testing.T) {
+	client, alice, acme, _ := setupClient()
+	before := client.ConsistencyToken()
+	token, err := client.DeleteWithToken(context.Background(), oso.NewFact("has_role", alice, oso.String("member"), acme))
+	if err != nil || token == "" || token == before {
+		t.Fatalf("DeleteWithToken = %q, %v, want a new token", token, err)
+	}
+	if latest := client.ConsistencyToken(); latest != token {
+		t.Fatalf("ConsistencyToken = %q, want %q", latest, token)
+	}
+}
+
+func TestRuleCallsBackIntoClient(t *testing.T) {
+	client, alice, acme, _ := setupClient()
+	flag := oso.NewFact("is_flagged", acme)
+	// The rule starts a write and then reads from the client while the
+	// writer is waiting, which deadlocks if rules run under the client's lock.
+	client.AddRule(func(facts Facts, actor oso.Value, action string, resource oso.Value) bool {
+		if action != "flag" {
+			return false
+		}
+		written := make(chan error, 1)
+		go func() { written <- client.Insert(flag) }()
+		time.Sleep(10 * time.Millisecond)
+		_, err := client.Get(flag)
+		return err == nil && <-written == nil
+	})
+
+	done := make(chan bool, 1)
+	go func() {
+		allowed, _ := client.Authorize(alice, "flag", acme)
+		done <- allowed
+	}()
+	select {
+	case allowed := <-done:
+		if !allowed {
+			t.Fatalf("Authorize = %t, want %t", allowed, true)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Authorize deadlocked in a rule which calls back into the client")
+	}
+}
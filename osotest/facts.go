@@ -0,0 +1,124 @@
+package osotest
+
+import (
+	"fmt"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+)
+
+// Facts is a read-only view of the facts visible to an authorization
+// decision: those stored in the [Client] plus any context facts.
+type Facts []oso.Fact
+
+// Reports whether the given fact is present.
+func (f Facts) Has(fact oso.Fact) bool {
+	for _, candidate := range f {
+		if factEqual(candidate, fact) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the facts matching the given [oso.Fact] or [oso.FactPattern].
+func (f Facts) Match(pattern oso.IntoFactPattern) []oso.Fact {
+	matches := []oso.Fact{}
+	for _, candidate := range f {
+		if matchPattern(pattern, candidate) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+func factEqual(a oso.Fact, b oso.Fact) bool {
+	if a.Predicate != b.Predicate || len(a.Args) != len(b.Args) {
+		return false
+	}
+	for i := range a.Args {
+		if a.Args[i] != b.Args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func matchPattern(pattern oso.IntoFactPattern, fact oso.Fact) bool {
+	switch p := pattern.(type) {
+	case oso.Fact:
+		return factEqual(p, fact)
+	case oso.FactPattern:
+		if p.Predicate != fact.Predicate || len(p.Args) != len(fact.Args) {
+			return false
+		}
+		for i, arg := range p.Args {
+			switch a := arg.(type) {
+			case nil:
+			case oso.Value:
+				if a != fact.Args[i] {
+					return false
+				}
+			case oso.ValueOfType:
+				if a.Type != fact.Args[i].Type {
+					return false
+				}
+			default:
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Mirrors the validation done by the real client, so that tests catch the
+// same mistakes.
+func validateValue(v oso.Value) error {
+	if v.Type == "" {
+		return fmt.Errorf("%w: Value must have a non-empty Type", oso.ErrInvalidValue)
+	}
+	if v.ID == "" {
+		return fmt.Errorf("%w: Value must have a non-empty ID", oso.ErrInvalidValue)
+	}
+	return nil
+}
+
+func validateFact(f oso.Fact) error {
+	for _, arg := range f.Args {
+		if err := validateValue(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateFacts(facts []oso.Fact) error {
+	for _, f := range facts {
+		if err := validateFact(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePattern(pattern oso.IntoFactPattern) error {
+	switch p := pattern.(type) {
+	case oso.Fact:
+		return validateFact(p)
+	case oso.FactPattern:
+		for _, arg := range p.Args {
+			switch a := arg.(type) {
+			case oso.Value:
+				if err := validateValue(a); err != nil {
+					return err
+				}
+			case oso.ValueOfType:
+				if a.Type == "" {
+					return fmt.Errorf("%w: ValueOfType must have non-empty Type", oso.ErrInvalidValue)
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported fact pattern %T", pattern)
+}
@@ -0,0 +1,93 @@
+package osotest
+
+import (
+	"context"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"github.com/osohq/go-oso-cloud/v2/internal/backend"
+)
+
+// Evaluates a query built with BuildQuery by brute force: each call is
+// checked in turn, trying every candidate value for the variables it binds.
+//
+// A call to "allow" with three arguments is decided by the client's roles and
+// rules. Any other call must match a stored or context fact.
+func (c *Client) evaluateQuery(ctx context.Context, query backend.Query) ([]map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	contextFacts, _ := query.ContextFacts.([]oso.Fact)
+	if err := validateFacts(contextFacts); err != nil {
+		return nil, err
+	}
+	s := c.snapshot(contextFacts)
+	calls := append([]backend.QueryCall{query.Predicate}, query.Calls...)
+	results := []map[string]string{}
+	binding := make(map[string]string)
+
+	var solve func(i int)
+	solve = func(i int) {
+		if i == len(calls) {
+			row := make(map[string]string, len(binding))
+			for name, value := range binding {
+				row[name] = value
+			}
+			results = append(results, row)
+			return
+		}
+		unbound := []string{}
+		for _, arg := range calls[i].Args {
+			if _, ok := binding[arg]; !ok && !containsString(unbound, arg) {
+				unbound = append(unbound, arg)
+			}
+		}
+		var assign func(j int)
+		assign = func(j int) {
+			if j == len(unbound) {
+				if s.holds(query.Variables, calls[i], binding) {
+					solve(i + 1)
+				}
+				return
+			}
+			name := unbound[j]
+			for _, candidate := range s.candidates(query.Variables[name]) {
+				binding[name] = candidate
+				assign(j + 1)
+			}
+			delete(binding, name)
+		}
+		assign(0)
+	}
+	solve(0)
+	return results, nil
+}
+
+// The values a variable could take: its constrained IDs if it has any, and
+// otherwise every value of its type that the client knows about.
+func (s *snapshot) candidates(variable backend.QueryVariable) []string {
+	if variable.IDs != nil {
+		return variable.IDs
+	}
+	ids := idsOfType(s.facts, variable.Type)
+	if variable.Type == "String" {
+		for _, actions := range s.actions {
+			for _, action := range actions {
+				if !containsString(ids, action) {
+					ids = append(ids, action)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+func (s *snapshot) holds(variables map[string]backend.QueryVariable, call backend.QueryCall, binding map[string]string) bool {
+	args := make([]oso.Value, 0, len(call.Args))
+	for _, name := range call.Args {
+		args = append(args, oso.NewValue(variables[name].Type, binding[name]))
+	}
+	if call.Predicate == "allow" && len(args) == 3 {
+		return s.allowed(args[0], args[1].ID, args[2])
+	}
+	return s.facts.Has(oso.NewFact(call.Predicate, args...))
+}
@@ -0,0 +1,517 @@
+package osotest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"github.com/osohq/go-oso-cloud/v2/internal/backend"
+)
+
+// Server is a local stand-in for Oso Cloud, serving the JSON API that the real
+// client talks to from an in-memory [Client]. Point an [oso.OsoClient] at
+// Server.URL to exercise it end to end without network access:
+//
+//	server := osotest.NewServer()
+//	defer server.Close()
+//	server.Client.AddRole("Repo", "member", "read")
+//
+//	client, _ := oso.New(server.URL, "e_0123456789_12345_osotesttoken01xiIn")
+//
+// Every response carries X-Request-ID and OsoOffset headers. The offset
+// increases with every write. Faults and latency can be injected to test
+// retries and fallback behaviour.
+type Server struct {
+	*httptest.Server
+	// The in-memory client backing the server. Use it to register roles and
+	// rules, and to seed or inspect facts.
+	Client *Client
+
+	mu           sync.Mutex
+	apiKey       string
+	offset       int64
+	latency      time.Duration
+	faults       []*Fault
+	expectations []Expectation
+	requests     map[string]int
+}
+
+// A Fault makes the server fail requests instead of serving them.
+type Fault struct {
+	// The API path to fail, eg. "/authorize". An empty Path fails every request.
+	Path string
+	// The status code to respond with, eg. 500 or 400.
+	StatusCode int
+	// The error message in the response body. Defaults to the status text.
+	Message string
+	// The number of requests to fail before the fault clears itself. Zero
+	// means the fault stays until [Server.ClearFaults] is called.
+	Count int
+}
+
+// An expected authorization result reported through a [oso.ParityHandle].
+type Expectation struct {
+	RequestID string
+	Expected  bool
+}
+
+// Start a server backed by a new, empty [Client]. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		Client:   NewClient(),
+		requests: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Reject requests that don't use the given API key with a 401. By default any
+// API key is accepted.
+func (s *Server) RequireAPIKey(apiKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = apiKey
+}
+
+// Delay every response by the given duration.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// Fail requests as described by the fault. Faults are checked in the order in
+// which they were injected.
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault)
+}
+
+// Remove all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Returns the number of requests received for the given API path, eg.
+// "/authorize", including failed ones.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// Returns the expected results reported to /expect, in order.
+func (s *Server) Expectations() []Expectation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Expectation{}, s.expectations...)
+}
+
+// Returns the current OsoOffset, which increases with every write.
+func (s *Server) Offset() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strconv.FormatInt(s.offset, 10)
+}
+
+// Records the request, and returns the fault to respond with (if any) and
+// the latency to apply.
+func (s *Server) admit(r *http.Request, path string) (*Fault, time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[path]++
+	authorized := s.apiKey == "" || r.Header.Get("Authorization") == "Bearer "+s.apiKey
+	for i, fault := range s.faults {
+		if fault.Path != "" && fault.Path != path {
+			continue
+		}
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return fault, s.latency, authorized
+	}
+	return nil, s.latency, authorized
+}
+
+func (s *Server) bumpOffset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset++
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Request-ID")
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	w.Header().Set("X-Request-ID", requestID)
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.TrimPrefix(r.URL.Path, "/api")
+	fault, latency, authorized := s.admit(r, path)
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fault != nil {
+		message := fault.Message
+		if message == "" {
+			message = http.StatusText(fault.StatusCode)
+		}
+		s.respondError(w, fault.StatusCode, message)
+		return
+	}
+	if !authorized {
+		s.respondError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+
+	result, mutation, err := s.route(r, path)
+	if err != nil {
+		status := http.StatusBadRequest
+		var routeErr *routeError
+		if errors.As(err, &routeErr) {
+			status = routeErr.status
+		}
+		s.respondError(w, status, err.Error())
+		return
+	}
+	if mutation {
+		s.bumpOffset()
+	}
+	w.Header().Set("OsoOffset", s.Offset())
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) respondError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("OsoOffset", s.Offset())
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiMessage{Message: message})
+}
+
+type routeError struct {
+	status  int
+	message string
+}
+
+func (e *routeError) Error() string {
+	return e.message
+}
+
+// Serves a single request, returning the response body and whether the
+// request was a write.
+func (s *Server) route(r *http.Request, path string) (interface{}, bool, error) {
+	endpoint := r.Method + " " + path
+	switch endpoint {
+	case "POST /batch":
+		var changesets []wireChangeset
+		if err := decode(r, &changesets); err != nil {
+			return nil, false, err
+		}
+		err := s.Client.BatchCtx(r.Context(), func(tx oso.BatchTransaction) {
+			for _, changeset := range changesets {
+				for _, f := range changeset.Inserts {
+					tx.Insert(f.toFact())
+				}
+				for _, p := range changeset.Deletes {
+					tx.Delete(p.toPattern())
+				}
+			}
+		})
+		return apiMessage{Message: "Batch applied"}, true, err
+
+	case "GET /facts":
+		return s.getFacts(r), false, nil
+
+	case "POST /authorize":
+		var q wireAuthorizeQuery
+		if err := decode(r, &q); err != nil {
+			return nil, false, err
+		}
+		allowed, err := s.Client.AuthorizeCtx(r.Context(), oso.NewValue(q.ActorType, q.ActorId), q.Action, oso.NewValue(q.ResourceType, q.ResourceId), &oso.AuthorizeOptions{ContextFacts: toFacts(q.ContextFacts)})
+		return map[string]bool{"allowed": allowed}, false, err
+
+	case "POST /authorize_resources":
+		var q wireAuthorizeResourcesQuery
+		if err := decode(r, &q); err != nil {
+			return nil, false, err
+		}
+		resources := make([]oso.Value, 0, len(q.Resources))
+		for _, resource := range q.Resources {
+			resources = append(resources, resource.toValue())
+		}
+		authorized, err := s.Client.AuthorizeResourcesCtx(r.Context(), oso.NewValue(q.ActorType, q.ActorId), q.Action, resources, &oso.AuthorizeOptions{ContextFacts: toFacts(q.ContextFacts)})
+		results := make([]wireValue, 0, len(authorized))
+		for _, resource := range authorized {
+			results = append(results, wireValue{Type: resource.Type, Id: resource.ID})
+		}
+		return map[string][]wireValue{"results": results}, false, err
+
+	case "POST /list":
+		var q wireListQuery
+		if err := decode(r, &q); err != nil {
+			return nil, false, err
+		}
+		results, err := s.Client.ListCtx(r.Context(), oso.NewValue(q.ActorType, q.ActorId), q.Action, q.ResourceType, toFacts(q.ContextFacts))
+		return map[string][]string{"results": results}, false, err
+
+	case "POST /actions":
+		var q wireActionsQuery
+		if err := decode(r, &q); err != nil {
+			return nil, false, err
+		}
+		results, err := s.Client.ActionsCtx(r.Context(), oso.NewValue(q.ActorType, q.ActorId), oso.NewValue(q.ResourceType, q.ResourceId), toFacts(q.ContextFacts))
+		return map[string][]string{"results": results}, false, err
+
+	case "POST /evaluate_query":
+		var q wireQuery
+		if err := decode(r, &q); err != nil {
+			return nil, false, err
+		}
+		results, err := s.Client.evaluateQuery(r.Context(), q.toQuery())
+		return map[string][]map[string]string{"results": results}, false, err
+
+	case "GET /policy_metadata":
+		metadata, err := s.Client.GetPolicyMetadataCtx(r.Context())
+		return map[string]*oso.PolicyMetadata{"metadata": metadata}, false, err
+
+	case "POST /policy":
+		var p struct {
+			Src string `json:"src"`
+		}
+		if err := decode(r, &p); err != nil {
+			return nil, false, err
+		}
+		return apiMessage{Message: "Policy updated"}, true, s.Client.PolicyCtx(r.Context(), p.Src)
+
+	case "POST /expect":
+		var e struct {
+			RequestID string `json:"request_id"`
+			Expected  bool   `json:"expected"`
+		}
+		if err := decode(r, &e); err != nil {
+			return nil, false, err
+		}
+		s.mu.Lock()
+		s.expectations = append(s.expectations, Expectation{RequestID: e.RequestID, Expected: e.Expected})
+		s.mu.Unlock()
+		return apiMessage{Message: "Expectation recorded"}, false, nil
+
+	case "GET /stats":
+		facts := s.Client.Facts()
+		return map[string]int{
+			"num_roles":     len(facts.Match(oso.NewFactPattern("has_role", nil, nil, nil))),
+			"num_relations": len(facts.Match(oso.NewFactPattern("has_relation", nil, nil, nil))),
+			"num_facts":     len(facts),
+		}, false, nil
+
+	case "POST /clear_data":
+		s.Client.Reset()
+		return apiMessage{Message: "Data cleared"}, true, nil
+
+	case "POST /authorize_query", "POST /list_query", "POST /actions_query", "POST /evaluate_query_local":
+		return nil, false, &routeError{http.StatusNotImplemented, ErrLocalNotSupported.Error()}
+	}
+	return nil, false, &routeError{http.StatusNotFound, fmt.Sprintf("no such endpoint: %s", endpoint)}
+}
+
+// Matches facts against the query parameters sent by Get, which look like
+// predicate=has_role&args.0.type=User&args.0.id=alice. Arguments without
+// parameters match anything.
+func (s *Server) getFacts(r *http.Request) []wireFact {
+	params := r.URL.Query()
+	results := []wireFact{}
+	for _, fact := range s.Client.Facts() {
+		if fact.Predicate != params.Get("predicate") {
+			continue
+		}
+		matches := true
+		for i, arg := range fact.Args {
+			typ, hasType := params[fmt.Sprintf("args.%d.type", i)]
+			id, hasId := params[fmt.Sprintf("args.%d.id", i)]
+			if (hasType && typ[0] != arg.Type) || (hasId && id[0] != arg.ID) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			results = append(results, fromFact(fact))
+		}
+	}
+	return results
+}
+
+func decode(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// The wire format of the Oso Cloud API, as sent by the client.
+
+type apiMessage struct {
+	Message string `json:"message"`
+}
+
+type wireValue struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+func (v wireValue) toValue() oso.Value {
+	return oso.NewValue(v.Type, v.Id)
+}
+
+type wireFact struct {
+	Predicate string      `json:"predicate"`
+	Args      []wireValue `json:"args"`
+}
+
+func (f wireFact) toFact() oso.Fact {
+	args := make([]oso.Value, 0, len(f.Args))
+	for _, arg := range f.Args {
+		args = append(args, arg.toValue())
+	}
+	return oso.NewFact(f.Predicate, args...)
+}
+
+func fromFact(f oso.Fact) wireFact {
+	args := make([]wireValue, 0, len(f.Args))
+	for _, arg := range f.Args {
+		args = append(args, wireValue{Type: arg.Type, Id: arg.ID})
+	}
+	return wireFact{Predicate: f.Predicate, Args: args}
+}
+
+func toFacts(facts []wireFact) []oso.Fact {
+	out := make([]oso.Fact, 0, len(facts))
+	for _, f := range facts {
+		out = append(out, f.toFact())
+	}
+	return out
+}
+
+type wireVariableValue struct {
+	Type *string `json:"type"`
+	Id   *string `json:"id"`
+}
+
+type wireFactPattern struct {
+	Predicate string              `json:"predicate"`
+	Args      []wireVariableValue `json:"args"`
+}
+
+func (p wireFactPattern) toPattern() oso.FactPattern {
+	args := make([]oso.ValuePattern, 0, len(p.Args))
+	for _, arg := range p.Args {
+		switch {
+		case arg.Type != nil && arg.Id != nil:
+			args = append(args, oso.NewValue(*arg.Type, *arg.Id))
+		case arg.Type != nil:
+			args = append(args, oso.NewValueOfType(*arg.Type))
+		default:
+			args = append(args, nil)
+		}
+	}
+	return oso.NewFactPattern(p.Predicate, args...)
+}
+
+type wireChangeset struct {
+	Inserts []wireFact        `json:"inserts"`
+	Deletes []wireFactPattern `json:"deletes"`
+}
+
+type wireAuthorizeQuery struct {
+	ActorType    string     `json:"actor_type"`
+	ActorId      string     `json:"actor_id"`
+	Action       string     `json:"action"`
+	ResourceType string     `json:"resource_type"`
+	ResourceId   string     `json:"resource_id"`
+	ContextFacts []wireFact `json:"context_facts"`
+}
+
+type wireAuthorizeResourcesQuery struct {
+	ActorType    string      `json:"actor_type"`
+	ActorId      string      `json:"actor_id"`
+	Action       string      `json:"action"`
+	Resources    []wireValue `json:"resources"`
+	ContextFacts []wireFact  `json:"context_facts"`
+}
+
+type wireListQuery struct {
+	ActorType    string     `json:"actor_type"`
+	ActorId      string     `json:"actor_id"`
+	Action       string     `json:"action"`
+	ResourceType string     `json:"resource_type"`
+	ContextFacts []wireFact `json:"context_facts"`
+}
+
+type wireActionsQuery struct {
+	ActorType    string     `json:"actor_type"`
+	ActorId      string     `json:"actor_id"`
+	ResourceType string     `json:"resource_type"`
+	ResourceId   string     `json:"resource_id"`
+	ContextFacts []wireFact `json:"context_facts"`
+}
+
+// A call is serialized as a [predicate, [variable names]] pair.
+type wireQueryCall backend.QueryCall
+
+func (c *wireQueryCall) UnmarshalJSON(data []byte) error {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 {
+		return errors.New("query call must be a [predicate, args] pair")
+	}
+	if err := json.Unmarshal(pair[0], &c.Predicate); err != nil {
+		return err
+	}
+	return json.Unmarshal(pair[1], &c.Args)
+}
+
+type wireQuery struct {
+	Predicate   wireQueryCall   `json:"predicate"`
+	Calls       []wireQueryCall `json:"calls"`
+	Constraints map[string]struct {
+		Type string   `json:"type"`
+		IDs  []string `json:"ids"`
+	} `json:"constraints"`
+	ContextFacts []wireFact `json:"context_facts"`
+}
+
+func (q wireQuery) toQuery() backend.Query {
+	calls := make([]backend.QueryCall, 0, len(q.Calls))
+	for _, call := range q.Calls {
+		calls = append(calls, backend.QueryCall(call))
+	}
+	variables := make(map[string]backend.QueryVariable, len(q.Constraints))
+	for name, constraint := range q.Constraints {
+		variables[name] = backend.QueryVariable{Type: constraint.Type, IDs: constraint.IDs}
+	}
+	return backend.Query{
+		Predicate:    backend.QueryCall(q.Predicate),
+		Calls:        calls,
+		Variables:    variables,
+		ContextFacts: toFacts(q.ContextFacts),
+	}
+}
@@ -0,0 +1,209 @@
+package osotest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+)
+
+const testApiKey = "e_0123456789_12345_osotesttoken01xiIn"
+
+func setupServer(t *testing.T) (*Server, oso.OsoClientCtx) {
+	server := NewServer()
+	t.Cleanup(server.Close)
+	server.Client.AddRole("Repo", "member", "read")
+	server.Client.AddRole("Repo", "owner", "read", "write")
+
+	client, err := oso.New(server.URL, testApiKey, oso.WithRetryPolicy(oso.RetryPolicy{MaxRetries: 0}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return server, client
+}
+
+func TestServer(t *testing.T) {
+	server, client := setupServer(t)
+	alice := oso.NewValue("User", "alice")
+	acme := oso.NewValue("Repo", "acme")
+	anvil := oso.NewValue("Repo", "anvil")
+
+	err := client.Batch(func(tx oso.BatchTransaction) {
+		tx.Insert(oso.NewFact("has_role", alice, oso.String("member"), acme))
+		tx.Insert(oso.NewFact("has_role", alice, oso.String("owner"), anvil))
+		tx.Insert(oso.NewFact("is_public", acme))
+	})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if err := client.Delete(oso.NewFactPattern("is_public", nil)); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if offset := server.Offset(); offset != "2" {
+		t.Fatalf("Offset = %s, want 2", offset)
+	}
+
+	facts, err := client.Get(oso.NewFactPattern("has_role", alice, nil, oso.NewValueOfType("Repo")))
+	if err != nil || len(facts) != 2 {
+		t.Fatalf("Get = %v, %v, want 2 facts", facts, err)
+	}
+	it, err := client.GetIter(oso.NewFactPattern("has_role", nil, nil, nil), &oso.GetIterOptions{BufferSize: 1})
+	if err != nil {
+		t.Fatalf("GetIter failed: %v", err)
+	}
+	var streamed []oso.Fact
+	for it.Next() {
+		streamed = append(streamed, it.Fact())
+	}
+	it.Close()
+	if it.Err() != nil || !reflect.DeepEqual(streamed, facts) {
+		t.Fatalf("GetIter = %v, %v, want %v", streamed, it.Err(), facts)
+	}
+
+	allowed, err := client.Authorize(alice, "write", anvil)
+	if err != nil || !allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+	}
+	allowed, err = client.Authorize(alice, "write", acme)
+	if err != nil || allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, false)
+	}
+
+	authorized, err := client.AuthorizeResources(alice, "write", []oso.Value{acme, anvil}, nil)
+	if err != nil || !reflect.DeepEqual(authorized, []oso.Value{anvil}) {
+		t.Fatalf("AuthorizeResources = %v, %v, want %v", authorized, err, []oso.Value{anvil})
+	}
+
+	repos, err := client.List(alice, "read", "Repo", nil)
+	if err != nil || !reflect.DeepEqual(repos, []string{"acme", "anvil"}) {
+		t.Fatalf("List = %v, %v, want %v", repos, err, []string{"acme", "anvil"})
+	}
+
+	actions, err := client.Actions(alice, anvil)
+	if err != nil || !reflect.DeepEqual(actions, []string{"read", "write"}) {
+		t.Fatalf("Actions = %v, %v, want %v", actions, err, []string{"read", "write"})
+	}
+
+	repo := oso.TypedVar("Repo")
+	repos, err = client.BuildQuery(oso.NewQueryFact("allow", alice, oso.String("write"), repo)).EvaluateValues(repo)
+	if err != nil || !reflect.DeepEqual(repos, []string{"anvil"}) {
+		t.Fatalf("EvaluateValues = %v, %v, want %v", repos, err, []string{"anvil"})
+	}
+
+	metadata, err := client.GetPolicyMetadata()
+	if err != nil || !reflect.DeepEqual(metadata.Resources["Repo"].Roles, []string{"member", "owner"}) {
+		t.Fatalf("GetPolicyMetadata = %+v, %v", metadata, err)
+	}
+
+	handle := oso.NewParityHandle()
+	if _, err := client.AuthorizeCtx(context.Background(), alice, "read", acme, &oso.AuthorizeOptions{ParityHandle: handle}); err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	if err := handle.Expect(true); err != nil {
+		t.Fatalf("Expect failed: %v", err)
+	}
+	if expectations := server.Expectations(); len(expectations) != 1 || !expectations[0].Expected {
+		t.Fatalf("Expectations = %v, want one expected result", expectations)
+	}
+}
+
+func TestServerHeaders(t *testing.T) {
+	server, _ := setupServer(t)
+
+	req, _ := http.NewRequest("POST", server.URL+"/api/clear_data", nil)
+	req.Header.Set("X-Request-ID", "test-request")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	res.Body.Close()
+	if id := res.Header.Get("X-Request-ID"); id != "test-request" {
+		t.Fatalf("X-Request-ID = %q, want %q", id, "test-request")
+	}
+	if offset := res.Header.Get("OsoOffset"); offset != "1" {
+		t.Fatalf("OsoOffset = %q, want %q", offset, "1")
+	}
+
+	res, err = http.Get(server.URL + "/api/stats")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	res.Body.Close()
+	if res.Header.Get("X-Request-ID") == "" {
+		t.Fatalf("X-Request-ID missing")
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	server, client := setupServer(t)
+	alice := oso.NewValue("User", "alice")
+	acme := oso.NewValue("Repo", "acme")
+
+	server.InjectFault(Fault{Path: "/authorize", StatusCode: 500, Message: "boom", Count: 1})
+	_, err := client.Authorize(alice, "read", acme)
+	var apiErr *oso.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 500 || apiErr.Message != "boom" || apiErr.RequestID == "" {
+		t.Fatalf("Authorize error = %v, want 500 APIError", err)
+	}
+	// The fault clears itself after Count requests.
+	if _, err := client.Authorize(alice, "read", acme); err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	if n := server.Requests("/authorize"); n != 2 {
+		t.Fatalf("Requests = %d, want 2", n)
+	}
+
+	server.InjectFault(Fault{StatusCode: 400})
+	if _, err := client.List(alice, "read", "Repo", nil); !errors.As(err, &apiErr) || apiErr.StatusCode != 400 {
+		t.Fatalf("List error = %v, want 400 APIError", err)
+	}
+	server.ClearFaults()
+
+	server.RequireAPIKey("e_other")
+	if _, err := client.Authorize(alice, "read", acme); !errors.Is(err, oso.ErrUnauthorized) {
+		t.Fatalf("Authorize error = %v, want ErrUnauthorized", err)
+	}
+	server.RequireAPIKey("")
+
+	server.SetLatency(200 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.AuthorizeCtx(ctx, alice, "read", acme, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("AuthorizeCtx error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestServerFallback(t *testing.T) {
+	primary, _ := setupServer(t)
+	fallback, _ := setupServer(t)
+	alice := oso.NewValue("User", "alice")
+	acme := oso.NewValue("Repo", "acme")
+	fallback.Client.Insert(oso.NewFact("has_role", alice, oso.String("member"), acme))
+
+	client, err := oso.New(primary.URL, testApiKey,
+		oso.WithFallbackUrl(fallback.URL),
+		oso.WithRetryPolicy(oso.RetryPolicy{MaxRetries: 0}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	primary.InjectFault(Fault{StatusCode: 503})
+	allowed, err := client.Authorize(alice, "read", acme)
+	if err != nil || !allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+	}
+	if primary.Requests("/authorize") != 1 || fallback.Requests("/authorize") != 1 {
+		t.Fatalf("expected one request to each server")
+	}
+
+	// Writes are never sent to the fallback.
+	err = client.Insert(oso.NewFact("has_role", alice, oso.String("owner"), acme))
+	var apiErr *oso.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 503 || apiErr.FallbackAttempted {
+		t.Fatalf("Insert error = %v, want 503 APIError without fallback", err)
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/osohq/go-oso-cloud/v2/osotrace
+
+go 1.20
+
+require (
+	github.com/osohq/go-oso-cloud/v2 v2.3.2-0.20261016205440-986757c941a5
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
+	github.com/hashicorp/go-retryablehttp v0.7.7 // indirect
+)
@@ -0,0 +1,86 @@
+// Package osotrace traces the calls an Oso Cloud client makes with
+// OpenTelemetry.
+//
+//	tracer := osotrace.NewTracer(otel.GetTracerProvider())
+//
+//	client, err := oso.New(url, apiKey, oso.WithTracer(tracer))
+//
+// Every call gets a client span, named after its endpoint (eg.
+// "oso.authorize"), which records the method, status code, retry count,
+// whether the fallback URL was used and the Oso request ID. W3C trace context
+// is sent to Oso Cloud and the fallback nodes.
+//
+// It's a separate module, so that only programs which use it depend on
+// OpenTelemetry.
+package osotrace
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/osohq/go-oso-cloud/v2/osotrace"
+
+// Tracer is an [oso.Tracer] which starts a span for every call.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+var _ oso.Tracer = (*Tracer)(nil)
+
+// Create a tracer which starts spans from the given provider.
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	return &Tracer{
+		tracer:     provider.Tracer(tracerName),
+		propagator: propagation.TraceContext{},
+	}
+}
+
+func (t *Tracer) StartCall(ctx context.Context, call oso.TracedCall) context.Context {
+	attributes := []attribute.KeyValue{
+		attribute.String("http.request.method", call.Method),
+		attribute.String("oso.endpoint", call.Endpoint),
+	}
+	if call.ActorType != "" {
+		attributes = append(attributes, attribute.String("oso.actor_type", call.ActorType))
+	}
+	if call.ResourceType != "" {
+		attributes = append(attributes, attribute.String("oso.resource_type", call.ResourceType))
+	}
+	ctx, _ = t.tracer.Start(ctx, "oso"+strings.ReplaceAll(call.Endpoint, "/", "."),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+	)
+	return ctx
+}
+
+func (t *Tracer) Inject(ctx context.Context, header http.Header) {
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+func (t *Tracer) EndCall(ctx context.Context, result oso.TracedResult) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int("oso.retry_count", result.Retries),
+		attribute.Bool("oso.fallback", result.Fallback),
+	)
+	if result.StatusCode != 0 {
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", result.StatusCode),
+			attribute.String("oso.request_id", result.RequestID),
+		)
+	}
+	if result.Err != nil {
+		span.RecordError(result.Err)
+		span.SetStatus(codes.Error, result.Err.Error())
+	}
+}
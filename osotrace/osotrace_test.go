@@ -0,0 +1,170 @@
+package osotrace
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	oso "github.com/osohq/go-oso-cloud/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// A tracer provider which keeps the spans it starts, and records them as they
+// end.
+type fakeTracerProvider struct {
+	embedded.TracerProvider
+	mu    sync.Mutex
+	ended []*fakeSpan
+}
+
+func (p *fakeTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return fakeTracer{provider: p}
+}
+
+func (p *fakeTracerProvider) spans() []*fakeSpan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*fakeSpan(nil), p.ended...)
+}
+
+func (p *fakeTracerProvider) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ended = nil
+}
+
+type fakeTracer struct {
+	embedded.Tracer
+	provider *fakeTracerProvider
+}
+
+func (t fakeTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	parent := trace.SpanContextFromContext(ctx)
+	config := trace.SpanContextConfig{TraceID: parent.TraceID(), TraceFlags: trace.FlagsSampled}
+	if !parent.IsValid() {
+		rand.Read(config.TraceID[:])
+	}
+	rand.Read(config.SpanID[:])
+	span := &fakeSpan{
+		provider:    t.provider,
+		name:        name,
+		parent:      parent,
+		spanContext: trace.NewSpanContext(config),
+		attributes:  map[attribute.Key]attribute.Value{},
+	}
+	start := trace.NewSpanStartConfig(opts...)
+	span.SetAttributes(start.Attributes()...)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type fakeSpan struct {
+	noop.Span
+	provider    *fakeTracerProvider
+	name        string
+	parent      trace.SpanContext
+	spanContext trace.SpanContext
+	attributes  map[attribute.Key]attribute.Value
+	status      codes.Code
+}
+
+func (s *fakeSpan) SpanContext() trace.SpanContext { return s.spanContext }
+func (s *fakeSpan) IsRecording() bool              { return true }
+func (s *fakeSpan) SetStatus(code codes.Code, _ string) {
+	s.status = code
+}
+func (s *fakeSpan) SetAttributes(attributes ...attribute.KeyValue) {
+	for _, kv := range attributes {
+		s.attributes[kv.Key] = kv.Value
+	}
+}
+func (s *fakeSpan) End(...trace.SpanEndOption) {
+	s.provider.mu.Lock()
+	defer s.provider.mu.Unlock()
+	s.provider.ended = append(s.provider.ended, s)
+}
+
+func TestTracer(t *testing.T) {
+	var primaryCalls int32
+	var traceparent atomic.Value
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent.Store(r.Header.Get("traceparent"))
+		w.Header().Set("X-Request-ID", "primary-request")
+		if r.URL.Path == "/api/batch" || (r.URL.Path == "/api/authorize" && atomic.AddInt32(&primaryCalls, 1) <= 2) {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "fallback-request")
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	defer fallback.Close()
+
+	provider := &fakeTracerProvider{}
+	o, err := oso.New(primary.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		oso.WithFallbackUrl(fallback.URL),
+		oso.WithRetryPolicy(oso.RetryPolicy{MaxRetries: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond}),
+		oso.WithTracer(NewTracer(provider)),
+		oso.WithTracedTypes())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	allowed, err := o.AuthorizeCtx(ctx, oso.NewValue("User", "alice"), "read", oso.NewValue("Repo", "acme"), nil)
+	parent.End()
+	if err != nil || !allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+	}
+
+	spans := provider.spans()
+	if len(spans) != 2 || spans[0].name != "oso.authorize" {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+	span := spans[0]
+	if span.parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("span is not a child of the caller's span")
+	}
+	attributes := span.attributes
+	expected := map[attribute.Key]attribute.Value{
+		"http.request.method":       attribute.StringValue("POST"),
+		"oso.endpoint":              attribute.StringValue("/authorize"),
+		"http.response.status_code": attribute.IntValue(200),
+		"oso.retry_count":           attribute.IntValue(1),
+		"oso.fallback":              attribute.BoolValue(true),
+		"oso.request_id":            attribute.StringValue("fallback-request"),
+		"oso.actor_type":            attribute.StringValue("User"),
+		"oso.resource_type":         attribute.StringValue("Repo"),
+	}
+	for key, value := range expected {
+		if attributes[key] != value {
+			t.Fatalf("attribute %s = %v, want %v", key, attributes[key].Emit(), value.Emit())
+		}
+	}
+	if header, _ := traceparent.Load().(string); header == "" || header[3:35] != span.spanContext.TraceID().String() {
+		t.Fatalf("traceparent = %q, want trace ID %s", header, span.spanContext.TraceID())
+	}
+
+	provider.reset()
+	if err := o.Insert(oso.NewFact("is_public", oso.NewValue("Repo", "acme"))); err == nil {
+		t.Fatalf("Insert succeeded, want error")
+	}
+	spans = provider.spans()
+	if len(spans) != 1 || spans[0].status != codes.Error {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+	if _, ok := spans[0].attributes["oso.actor_type"]; ok {
+		t.Fatalf("types recorded for a write")
+	}
+}
@@ -0,0 +1,371 @@
+package oso
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// How the placeholders of bound arguments are written in parameterized SQL.
+type Placeholder int
+
+const (
+	// Numbered placeholders, as used by Postgres: $1, $2, ...
+	PlaceholderDollar Placeholder = iota
+	// Question marks, as used by MySQL and SQLite (and by GORM, which rewrites
+	// them for its dialect).
+	PlaceholderQuestion
+)
+
+func (p Placeholder) format(n int) string {
+	if p == PlaceholderQuestion {
+		return "?"
+	}
+	return "$" + strconv.Itoa(n)
+}
+
+// Rewrites SQL returned by one of the *Local methods, replacing string and
+// numeric literals with placeholders, and returns the literals' values as
+// arguments to bind to them, in order. Strings are bound as strings, integers
+// as int64 and other numbers as float64. Numbered placeholders start at
+// $offset+1, so that the SQL can be added to a query which already binds
+// offset arguments; offset is ignored for [PlaceholderQuestion].
+//
+// Numbers are only bound where they are compared with something: after a
+// comparison operator, like n = 1, or in an IN list, like n IN (1, 2).
+// Elsewhere, like SELECT 1 or ORDER BY 1, a placeholder's type couldn't be
+// inferred, so they are left as they are. So are identifiers, comments and
+// other literals, including:
+//   - strings with a prefix, like E'\n', or a type, like INTERVAL '1 day'
+//   - dollar-quoted strings, like $tag$...$tag$
+//
+// It returns an error if the SQL already contains placeholders. With
+// [PlaceholderQuestion], it also returns an error if a ? remains in text that
+// is left as it is, like a comment or a quoted identifier, since it would be
+// mistaken for a placeholder when the arguments are bound.
+func Parameterize(sql string, placeholder Placeholder, offset int) (string, []interface{}, error) {
+	var out strings.Builder
+	args := []interface{}{}
+	bind := func(value interface{}) {
+		args = append(args, value)
+		out.WriteString(placeholder.format(offset + len(args)))
+	}
+	raw := func(text string) error {
+		if placeholder == PlaceholderQuestion && strings.Contains(text, "?") {
+			return errors.New("SQL contains a ? which isn't a placeholder")
+		}
+		out.WriteString(text)
+		return nil
+	}
+	// The bare word preceding the current token, in upper case, or "" if the
+	// preceding token wasn't one.
+	prevWord := ""
+	// Whether the current token is compared with something: it follows a
+	// comparison operator, or starts an item of an IN list.
+	compared := false
+	// The depth of parentheses, and the depths at which IN lists are open.
+	depth := 0
+	inLists := []int{}
+	inList := func() bool {
+		return len(inLists) > 0 && inLists[len(inLists)-1] == depth
+	}
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			out.WriteByte(c)
+			i++
+			continue
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			if err := raw(sql[i : i+end]); err != nil {
+				return "", nil, err
+			}
+			i += end
+			continue
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return "", nil, errors.New("unterminated comment in SQL")
+			}
+			if err := raw(sql[i : i+2+end+2]); err != nil {
+				return "", nil, err
+			}
+			i += 2 + end + 2
+			continue
+		case c == '\'' || c == '"':
+			value, end, err := scanQuoted(sql, i, c)
+			if err != nil {
+				return "", nil, err
+			}
+			if c == '\'' && !prefixed(sql, i) && !typeName(prevWord) {
+				bind(value)
+			} else {
+				// A quoted identifier, or a prefixed or typed literal.
+				if err := raw(sql[i:end]); err != nil {
+					return "", nil, err
+				}
+			}
+			i = end
+		case c == '$' && !prefixed(sql, i) && i+1 < len(sql) && isDigit(sql[i+1]):
+			if placeholder == PlaceholderDollar {
+				return "", nil, errors.New("SQL already contains placeholders")
+			}
+			out.WriteByte(c)
+			i++
+		case c == '$' && !prefixed(sql, i):
+			end, err := scanDollarQuoted(sql, i)
+			if err != nil {
+				return "", nil, err
+			}
+			if err := raw(sql[i:end]); err != nil {
+				return "", nil, err
+			}
+			i = end
+		case placeholder == PlaceholderQuestion && c == '?':
+			return "", nil, errors.New("SQL already contains placeholders")
+		case (isDigit(c) || c == '.' && i+1 < len(sql) && isDigit(sql[i+1])) && !prefixed(sql, i) && !member(sql, i):
+			end := scanNumber(sql, i)
+			value, ok := parseNumber(sql[i:end])
+			if ok && compared && !(end < len(sql) && isWordByte(sql[end])) {
+				bind(value)
+			} else {
+				out.WriteString(sql[i:end])
+			}
+			i = end
+		case isWordByte(c) && !isDigit(c) && c != '$':
+			end := i
+			for end < len(sql) && isWordByte(sql[end]) {
+				end++
+			}
+			word := strings.ToUpper(sql[i:end])
+			out.WriteString(sql[i:end])
+			i = end
+			if compared && inList() && (word == "SELECT" || word == "WITH" || word == "VALUES") {
+				// A subquery rather than a list of values.
+				inLists = inLists[:len(inLists)-1]
+			}
+			prevWord = word
+			compared = false
+			continue
+		case strings.IndexByte("=<>!", c) >= 0:
+			end := i
+			for end < len(sql) && strings.IndexByte("=<>!", sql[end]) >= 0 {
+				end++
+			}
+			op := sql[i:end]
+			out.WriteString(op)
+			i = end
+			prevWord = ""
+			compared = comparisonOperators[op]
+			continue
+		case (c == '-' || c == '+') && compared:
+			// The sign of a number being compared.
+			out.WriteByte(c)
+			i++
+			continue
+		case c == '(':
+			out.WriteByte(c)
+			i++
+			depth++
+			if prevWord == "IN" {
+				inLists = append(inLists, depth)
+				prevWord = ""
+				compared = true
+				continue
+			}
+		case c == ')':
+			if inList() {
+				inLists = inLists[:len(inLists)-1]
+			}
+			out.WriteByte(c)
+			i++
+			depth--
+		case c == ',' && inList():
+			out.WriteByte(c)
+			i++
+			prevWord = ""
+			compared = true
+			continue
+		default:
+			out.WriteByte(c)
+			i++
+		}
+		prevWord = ""
+		compared = false
+	}
+	return out.String(), args, nil
+}
+
+// Returns the unescaped contents of the quoted text starting at sql[start],
+// and the index following its closing quote. Quotes are escaped by doubling
+// them.
+func scanQuoted(sql string, start int, quote byte) (string, int, error) {
+	var value strings.Builder
+	for i := start + 1; i < len(sql); i++ {
+		if sql[i] != quote {
+			value.WriteByte(sql[i])
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			value.WriteByte(quote)
+			i++
+			continue
+		}
+		return value.String(), i + 1, nil
+	}
+	return "", 0, errors.New("unterminated quoted text in SQL")
+}
+
+// Reports whether sql[i] directly follows part of a word, eg. the E of E'\n'
+// or the a of a$1, or the & of U&'...'.
+func prefixed(sql string, i int) bool {
+	return i > 0 && (isWordByte(sql[i-1]) || sql[i-1] == '&')
+}
+
+// Reports whether sql[i] follows a . that accesses a member, eg. the 1 of t.1
+// or of "t".1, rather than starting a number.
+func member(sql string, i int) bool {
+	return i > 1 && sql[i-1] == '.' && (isWordByte(sql[i-2]) || sql[i-2] == '"' || sql[i-2] == ')')
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z') || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Keywords which may precede a value in SQL, and so aren't the type of a
+// typed literal like INTERVAL '1 day'.
+var valueKeywords = map[string]bool{
+	"ALL": true, "AND": true, "ANY": true, "BETWEEN": true, "BY": true,
+	"CASE": true, "DISTINCT": true, "ELSE": true, "ESCAPE": true, "FROM": true,
+	"HAVING": true, "ILIKE": true, "IN": true, "IS": true, "LIKE": true,
+	"LIMIT": true, "NOT": true, "OFFSET": true, "ON": true, "OR": true,
+	"RETURNING": true, "SELECT": true, "SIMILAR": true, "SOME": true,
+	"THEN": true, "TO": true, "VALUES": true, "WHEN": true, "WHERE": true,
+}
+
+// Operators after which a number is compared with something.
+var comparisonOperators = map[string]bool{
+	"=": true, "<>": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true,
+}
+
+// Reports whether word, which precedes a string literal, is its type.
+func typeName(word string) bool {
+	return word != "" && !valueKeywords[word]
+}
+
+// Returns the index following the dollar-quoted string starting at sql[start],
+// eg. $tag$text$tag$. A $ which doesn't open one is returned on its own.
+func scanDollarQuoted(sql string, start int) (int, error) {
+	end := start + 1
+	for end < len(sql) && isWordByte(sql[end]) && sql[end] != '$' {
+		end++
+	}
+	if end == len(sql) || sql[end] != '$' || (end > start+1 && isDigit(sql[start+1])) {
+		return start + 1, nil
+	}
+	tag := sql[start : end+1]
+	close := strings.Index(sql[end+1:], tag)
+	if close < 0 {
+		return 0, errors.New("unterminated dollar-quoted text in SQL")
+	}
+	return end + 1 + close + len(tag), nil
+}
+
+// Returns the index following the number starting at sql[start], eg. 1, 1.5,
+// .5 or 1e-3.
+func scanNumber(sql string, start int) int {
+	i := start
+	for i < len(sql) && isDigit(sql[i]) {
+		i++
+	}
+	if i < len(sql) && sql[i] == '.' {
+		i++
+		for i < len(sql) && isDigit(sql[i]) {
+			i++
+		}
+	}
+	if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+		j := i + 1
+		if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+			j++
+		}
+		if j < len(sql) && isDigit(sql[j]) {
+			for i = j; i < len(sql) && isDigit(sql[i]); i++ {
+			}
+		}
+	}
+	return i
+}
+
+// Returns the value of a numeric literal: an int64 if it's an integer in
+// range, and otherwise a float64.
+func parseNumber(literal string) (interface{}, bool) {
+	if !strings.ContainsAny(literal, ".eE") {
+		n, err := strconv.ParseInt(literal, 10, 64)
+		return n, err == nil
+	}
+	f, err := strconv.ParseFloat(literal, 64)
+	return f, err == nil
+}
+
+// Like [OsoClientImpl.AuthorizeLocalCtx], but returns parameterized SQL and
+// the arguments to bind to it; see [Parameterize].
+func (c *OsoClientImpl) AuthorizeLocalParamsCtx(ctx context.Context, actor Value, action string, resource Value, options *AuthorizeOptions, placeholder Placeholder) (string, []interface{}, error) {
+	sql, err := c.AuthorizeLocalCtx(ctx, actor, action, resource, options)
+	if err != nil {
+		return "", nil, err
+	}
+	return Parameterize(sql, placeholder, 0)
+}
+
+// Like [OsoClientImpl.ListLocalCtx], but returns a parameterized filter and
+// the arguments to bind to it; see [Parameterize]. With [PlaceholderDollar],
+// the filter's placeholders start at $offset+1, so pass the number of
+// arguments the rest of the query binds before the filter.
+func (c *OsoClientImpl) ListLocalParamsCtx(ctx context.Context, actor Value, action string, resourceType string, column string, contextFacts []Fact, placeholder Placeholder, offset int) (string, []interface{}, error) {
+	sql, err := c.ListLocalCtx(ctx, actor, action, resourceType, column, contextFacts)
+	if err != nil {
+		return "", nil, err
+	}
+	return Parameterize(sql, placeholder, offset)
+}
+
+// Like [OsoClientImpl.ActionsLocalCtx], but returns parameterized SQL and the
+// arguments to bind to it; see [Parameterize].
+func (c *OsoClientImpl) ActionsLocalParamsCtx(ctx context.Context, actor Value, resource Value, contextFacts []Fact, placeholder Placeholder) (string, []interface{}, error) {
+	sql, err := c.ActionsLocalCtx(ctx, actor, resource, contextFacts)
+	if err != nil {
+		return "", nil, err
+	}
+	return Parameterize(sql, placeholder, 0)
+}
+
+// Like [QueryBuilder.EvaluateLocalSelect], but returns parameterized SQL and
+// the arguments to bind to it; see [Parameterize].
+func (this QueryBuilder) EvaluateLocalSelectParams(columnNamesToQueryVars map[string]Variable, placeholder Placeholder) (string, []interface{}, error) {
+	sql, err := this.EvaluateLocalSelect(columnNamesToQueryVars)
+	if err != nil {
+		return "", nil, err
+	}
+	return Parameterize(sql, placeholder, 0)
+}
+
+// Like [QueryBuilder.EvaluateLocalFilter], but returns a parameterized filter
+// and the arguments to bind to it; see [Parameterize] and
+// [OsoClientImpl.ListLocalParamsCtx].
+func (this QueryBuilder) EvaluateLocalFilterParams(columnName string, queryVar Variable, placeholder Placeholder, offset int) (string, []interface{}, error) {
+	sql, err := this.EvaluateLocalFilter(columnName, queryVar)
+	if err != nil {
+		return "", nil, err
+	}
+	return Parameterize(sql, placeholder, offset)
+}
@@ -0,0 +1,130 @@
+package oso
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParameterize(t *testing.T) {
+	cases := []struct {
+		sql         string
+		placeholder Placeholder
+		offset      int
+		expected    string
+		args        []interface{}
+	}{
+		{
+			`SELECT id FROM "repo" WHERE id IN ('acme', 'it''s') AND n = 1`,
+			PlaceholderDollar, 0,
+			`SELECT id FROM "repo" WHERE id IN ($1, $2) AND n = $3`,
+			[]interface{}{"acme", "it's", int64(1)},
+		},
+		{
+			`"repo"."id" = 'acme'`,
+			PlaceholderDollar, 2,
+			`"repo"."id" = $3`,
+			[]interface{}{"acme"},
+		},
+		{
+			`SELECT 'a' AS "who's" -- 'b'` + "\n" + `/* 'c' */ WHERE x = 'd'`,
+			PlaceholderQuestion, 2,
+			`SELECT ? AS "who's" -- 'b'` + "\n" + `/* 'c' */ WHERE x = ?`,
+			[]interface{}{"a", "d"},
+		},
+		{
+			`SELECT E'\n', '' FROM t`,
+			PlaceholderDollar, 0,
+			`SELECT E'\n', $1 FROM t`,
+			[]interface{}{""},
+		},
+		{
+			`SELECT true AS allowed`,
+			PlaceholderQuestion, 0,
+			`SELECT true AS allowed`,
+			[]interface{}{},
+		},
+		{
+			`SELECT t1.n FROM t1 WHERE n > -2.5 AND m < .5e2 AND k <> 99999999999999999999 LIMIT 10`,
+			PlaceholderDollar, 0,
+			`SELECT t1.n FROM t1 WHERE n > -$1 AND m < $2 AND k <> 99999999999999999999 LIMIT 10`,
+			[]interface{}{2.5, 50.0},
+		},
+		{
+			`SELECT EXISTS(SELECT 1 FROM t WHERE n IN (1, -2) AND m NOT IN (SELECT 1 FROM u) AND (k >= 3 OR k = n + 1))`,
+			PlaceholderDollar, 0,
+			`SELECT EXISTS(SELECT 1 FROM t WHERE n IN ($1, -$2) AND m NOT IN (SELECT 1 FROM u) AND (k >= $3 OR k = n + 1))`,
+			[]interface{}{int64(1), int64(2), int64(3)},
+		},
+		{
+			`SELECT a, b FROM t WHERE c = 1 ORDER BY 2 DESC, 1 GROUP BY 1`,
+			PlaceholderDollar, 0,
+			`SELECT a, b FROM t WHERE c = $1 ORDER BY 2 DESC, 1 GROUP BY 1`,
+			[]interface{}{int64(1)},
+		},
+		{
+			`SELECT * FROM t WHERE d > now() - INTERVAL '1 day' AND e = DATE '2024-01-01' AND f LIKE 'x%'`,
+			PlaceholderDollar, 0,
+			`SELECT * FROM t WHERE d > now() - INTERVAL '1 day' AND e = DATE '2024-01-01' AND f LIKE $1`,
+			[]interface{}{"x%"},
+		},
+		{
+			`SELECT $$it's$$, $tag$ 'a' $$ 1 $tag$, 'b' FROM t`,
+			PlaceholderDollar, 0,
+			`SELECT $$it's$$, $tag$ 'a' $$ 1 $tag$, $1 FROM t`,
+			[]interface{}{"b"},
+		},
+	}
+	for _, c := range cases {
+		sql, args, err := Parameterize(c.sql, c.placeholder, c.offset)
+		if err != nil || sql != c.expected || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("Parameterize(%q, %d) = %q, %#v, %v, want %q, %#v", c.sql, c.offset, sql, args, err, c.expected, c.args)
+		}
+	}
+
+	for _, c := range []struct {
+		sql         string
+		placeholder Placeholder
+	}{
+		{`SELECT 'unterminated`, PlaceholderDollar},
+		{`SELECT 1 /* unterminated`, PlaceholderDollar},
+		{`SELECT $tag$unterminated$$`, PlaceholderDollar},
+		{`SELECT $1`, PlaceholderDollar},
+		{`SELECT ?`, PlaceholderQuestion},
+		{`SELECT 'a' AS "who's?" -- 'b'` + "\n" + `/* 'c' */ WHERE x = 'd'`, PlaceholderQuestion},
+		{`SELECT 'a' -- why?`, PlaceholderQuestion},
+		{`SELECT $$?$$`, PlaceholderQuestion},
+	} {
+		if _, _, err := Parameterize(c.sql, c.placeholder, 0); err == nil {
+			t.Errorf("Parameterize(%q) succeeded, want error", c.sql)
+		}
+	}
+}
+
+func TestLocalParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"sql": `"repo"."id" IN ('acme', 'anvils')`})
+	}))
+	defer server.Close()
+	client, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+	alice := NewValue("User", "alice")
+
+	filter, args, err := client.ListLocalParamsCtx(ctx, alice, "read", "Repo", `"repo"."id"`, nil, PlaceholderDollar, 1)
+	if err != nil || filter != `"repo"."id" IN ($2, $3)` || !reflect.DeepEqual(args, []interface{}{"acme", "anvils"}) {
+		t.Fatalf("ListLocalParamsCtx = %q, %v, %v", filter, args, err)
+	}
+
+	repo := TypedVar("Repo")
+	query := client.BuildQuery(NewQueryFact("allow", alice, String("read"), repo))
+	filter, args, err = query.EvaluateLocalFilterParams(`"repo"."id"`, repo, PlaceholderQuestion, 0)
+	if err != nil || filter != `"repo"."id" IN (?, ?)` || len(args) != 2 {
+		t.Fatalf("EvaluateLocalFilterParams = %q, %v, %v", filter, args, err)
+	}
+}
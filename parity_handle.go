@@ -1,6 +1,7 @@
 package oso
 
 import (
+	"context"
 	"errors"
 	"fmt"
 )
@@ -84,6 +85,9 @@ func (p *ParityHandle) send() error {
 		Expected:  *p.expected,
 	}
 
-	_, err := p.api.postExpectedResult(expectedResult)
+	_, err := p.api.postExpectedResult(context.Background(), expectedResult)
+	if err != nil {
+		p.api.logger.log(levelError, "oso: failed to send parity expectation", "request_id", *p.requestID, "error", err)
+	}
 	return err
 }
@@ -1,6 +1,7 @@
 package oso
 
 import (
+	"context"
 	"os"
 	"testing"
 )
@@ -49,13 +50,13 @@ func TestParityHandleSuccess(t *testing.T) {
 		t.Fatalf("AuthorizeWithOptions failed: %v", err)
 	}
 
-	osoImpl := oso.(OsoClientImpl)
+	osoImpl := oso.(*OsoClientImpl)
 	expectedResult := expectedResult{
 		RequestID: *parityHandle.requestID,
 		Expected:  true,
 	}
 
-	_, err = osoImpl.postExpectedResult(expectedResult)
+	_, err = osoImpl.postExpectedResult(context.Background(), expectedResult)
 	if err != nil {
 		t.Fatalf("postExpectedResult failed: %v", err)
 	}
@@ -1,11 +1,14 @@
 package oso
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
 	"reflect"
+
+	"github.com/osohq/go-oso-cloud/v2/internal/backend"
 )
 
 type queryId struct{ id string }
@@ -120,7 +123,8 @@ func TypedVar(Type string) Variable {
 //
 // After building your query, run it and get the results by calling one of the Evaluate* methods.
 type QueryBuilder struct {
-	oso          OsoClientImpl
+	oso          queryBackend
+	ctx          context.Context
 	predicate    apiQueryCall
 	calls        []apiQueryCall
 	constraints  map[queryId]queryConstraint
@@ -128,8 +132,14 @@ type QueryBuilder struct {
 	Error        error
 }
 
-func newBuilder(oso OsoClientImpl, fact QueryFact) QueryBuilder {
-	this := QueryBuilder{oso: oso, calls: []apiQueryCall{}, constraints: map[queryId]queryConstraint{}}
+// The part of the client a QueryBuilder needs to evaluate queries.
+type queryBackend interface {
+	postQuery(ctx context.Context, data query) (*queryResult, error)
+	postQueryLocal(ctx context.Context, query query, mode interface{}) (*localQueryResult, error)
+}
+
+func newBuilder(oso queryBackend, fact QueryFact) QueryBuilder {
+	this := QueryBuilder{oso: oso, ctx: context.Background(), calls: []apiQueryCall{}, constraints: map[queryId]queryConstraint{}}
 	args := make([]queryId, 0, len(fact.Args))
 	for _, arg := range fact.Args {
 		id := this.pushArg(arg)
@@ -147,6 +157,7 @@ func (this QueryBuilder) clone() QueryBuilder {
 
 	return QueryBuilder{
 		oso:          this.oso,
+		ctx:          this.ctx,
 		predicate:    this.predicate,
 		calls:        append([]apiQueryCall{}, this.calls...),
 		constraints:  constraints,
@@ -236,6 +247,58 @@ func (this QueryBuilder) WithContextFacts(facts []Fact) QueryBuilder {
 	return out
 }
 
+// Evaluates queries with a function rather than by calling Oso Cloud, for the
+// query builders of other client implementations; see [backend.NewQueryBuilder].
+type queryFuncBackend backend.QueryFunc
+
+func (f queryFuncBackend) postQuery(ctx context.Context, data query) (*queryResult, error) {
+	results, err := f(ctx, data.export())
+	if err != nil {
+		return nil, err
+	}
+	return &queryResult{Results: results}, nil
+}
+
+func (f queryFuncBackend) postQueryLocal(ctx context.Context, query query, mode interface{}) (*localQueryResult, error) {
+	return nil, errors.New("local queries are not supported by this QueryBuilder")
+}
+
+func (q query) export() backend.Query {
+	exportCall := func(call apiQueryCall) backend.QueryCall {
+		args := make([]string, 0, len(call.args))
+		for _, arg := range call.args {
+			args = append(args, arg.id)
+		}
+		return backend.QueryCall{Predicate: call.predicate, Args: args}
+	}
+	calls := make([]backend.QueryCall, 0, len(q.Calls))
+	for _, call := range q.Calls {
+		calls = append(calls, exportCall(call))
+	}
+	variables := make(map[string]backend.QueryVariable, len(q.Constraints))
+	for name, constraint := range q.Constraints {
+		constraint = constraint.clone()
+		variables[name] = backend.QueryVariable{Type: constraint.Type, IDs: constraint.IDs}
+	}
+	return backend.Query{
+		Predicate:    exportCall(q.Predicate),
+		Calls:        calls,
+		Variables:    variables,
+		ContextFacts: mapFromInternalFacts(q.ContextFacts),
+	}
+}
+
+// Bind the query to a context. The context is attached to the HTTP request made by
+// whichever Evaluate* method is eventually called, so cancelling it aborts the query.
+func (this QueryBuilder) WithCtx(ctx context.Context) QueryBuilder {
+	if this.Error != nil {
+		return this
+	}
+	out := this.clone()
+	out.ctx = ctx
+	return out
+}
+
 func (this QueryBuilder) asQuery() (query, error) {
 	constraints := make(map[string]queryConstraint)
 	for k, v := range this.constraints {
@@ -273,7 +336,7 @@ func (this QueryBuilder) EvaluateExists() (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	results, err := this.oso.postQuery(query)
+	results, err := this.oso.postQuery(this.ctx, query)
 	if err != nil {
 		return false, err
 	}
@@ -296,7 +359,7 @@ func (this QueryBuilder) EvaluateValues(t Variable) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	results, err := this.oso.postQuery(query)
+	results, err := this.oso.postQuery(this.ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -329,7 +392,7 @@ func (this QueryBuilder) EvaluateCombinations(ts []Variable) ([][]string, error)
 	if err != nil {
 		return nil, err
 	}
-	results, err := this.oso.postQuery(query)
+	results, err := this.oso.postQuery(this.ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -394,7 +457,7 @@ func (this QueryBuilder) Evaluate(out interface{}, arg interface{}) error {
 	if err != nil {
 		return err
 	}
-	results, err := this.oso.postQuery(query)
+	results, err := this.oso.postQuery(this.ctx, query)
 	if err != nil {
 		return err
 	}
@@ -544,7 +607,7 @@ func (this QueryBuilder) EvaluateLocalSelect(columnNamesToQueryVars map[string]V
 		queryVarsToColumnNames[id] = columnName
 	}
 
-	result, err := this.oso.postQueryLocal(query, localQuerySelect(queryVarsToColumnNames))
+	result, err := this.oso.postQueryLocal(this.ctx, query, localQuerySelect(queryVarsToColumnNames))
 	if err != nil {
 		return "", err
 	}
@@ -564,7 +627,7 @@ func (this QueryBuilder) EvaluateLocalFilter(columnName string, queryVar Variabl
 		return "", err
 	}
 
-	result, err := this.oso.postQueryLocal(query, localQueryFilter(columnName, queryVar.id.id))
+	result, err := this.oso.postQueryLocal(this.ctx, query, localQueryFilter(columnName, queryVar.id.id))
 	if err != nil {
 		return "", err
 	}
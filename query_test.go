@@ -1,13 +1,17 @@
 package oso
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
+
+	"github.com/osohq/go-oso-cloud/v2/internal/backend"
 )
 
-func setupClient() OsoClientImpl {
-	o := NewClient("http://localhost:8081", "e_0123456789_12345_osotesttoken01xiIn").(OsoClientImpl)
-	o.clearData()
+func setupClient() *OsoClientImpl {
+	o := NewClient("http://localhost:8081", "e_0123456789_12345_osotesttoken01xiIn").(*OsoClientImpl)
+	o.clearData(context.Background())
 	o.Policy(`
 		global {
 		  permissions = ["create_repository"];
@@ -64,7 +68,7 @@ func setupClient() OsoClientImpl {
 	return o
 }
 
-func teardown(o OsoClientImpl) {
+func teardown(o *OsoClientImpl) {
 	o.Batch(func(tx BatchTransaction) {
 		tx.Delete(NewFactPattern("has_role", nil, nil, nil))
 		tx.Delete(NewFactPattern("has_relation", nil, nil, nil))
@@ -477,3 +481,60 @@ func TestMultiMap(t *testing.T) {
 		t.Fatalf("expected multiple map values to result in error")
 	}
 }
+
+func TestNewQueryBuilder(t *testing.T) {
+	actor := NewValue("User", "alice")
+	repo := TypedVar("Repo")
+	var got backend.Query
+	builder := backend.NewQueryBuilder(NewQueryFact("allow", actor, String("read"), repo), func(ctx context.Context, q backend.Query) ([]map[string]string, error) {
+		got = q
+		return []map[string]string{{repo.id.id: "acme"}, {repo.id.id: "anvil"}}, nil
+	}).(QueryBuilder)
+
+	repos, err := builder.In(repo, []string{"acme", "anvil"}).
+		WithContextFacts([]Fact{NewFact("is_public", NewValue("Repo", "acme"))}).
+		EvaluateValues(repo)
+	if err != nil || !reflect.DeepEqual(repos, []string{"acme", "anvil"}) {
+		t.Fatalf("EvaluateValues = %v, %v, want %v", repos, err, []string{"acme", "anvil"})
+	}
+
+	args := got.Predicate.Args
+	if got.Predicate.Predicate != "allow" || len(args) != 3 || args[2] != repo.id.id || len(got.Calls) != 0 {
+		t.Fatalf("unexpected query %+v", got)
+	}
+	expectedVariables := map[string]backend.QueryVariable{
+		args[0]:    {Type: "User", IDs: []string{"alice"}},
+		args[1]:    {Type: "String", IDs: []string{"read"}},
+		repo.id.id: {Type: "Repo", IDs: []string{"acme", "anvil"}},
+	}
+	if !reflect.DeepEqual(got.Variables, expectedVariables) {
+		t.Fatalf("Variables = %v, want %v", got.Variables, expectedVariables)
+	}
+	if !reflect.DeepEqual(got.ContextFacts, []Fact{NewFact("is_public", NewValue("Repo", "acme"))}) {
+		t.Fatalf("ContextFacts = %v", got.ContextFacts)
+	}
+
+	if _, err := builder.EvaluateLocalFilter("id", repo); err == nil {
+		t.Fatalf("EvaluateLocalFilter succeeded, want error")
+	}
+}
+
+func TestRecordBatch(t *testing.T) {
+	insert := NewFact("has_role", NewValue("User", "alice"), String("member"), NewValue("Repo", "acme"))
+	pattern := NewFactPattern("has_role", nil, nil, NewValueOfType("Repo"))
+	operations, err := backend.RecordBatch(func(tx BatchTransaction) {
+		tx.Insert(insert)
+		tx.Delete(pattern)
+	})
+	expected := []backend.Operation{{Insert: &insert}, {Delete: pattern}}
+	if err != nil || !reflect.DeepEqual(operations, expected) {
+		t.Fatalf("RecordBatch = %v, %v, want %v", operations, err, expected)
+	}
+
+	_, err = backend.RecordBatch(func(tx BatchTransaction) {
+		tx.Delete(NewFactPattern("has_role", NewValueOfType("")))
+	})
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("RecordBatch error = %v, want ErrInvalidValue", err)
+	}
+}
@@ -0,0 +1,179 @@
+package oso
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+type idempotencyKeyKey struct{}
+
+// Returns a context that sends the given idempotency key with writes, in an
+// Idempotency-Key header. Writes to "/batch" are only retried when they have
+// one, since Oso Cloud can use it to recognise a write it has already applied.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+func idempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyKey{}).(string)
+	return key
+}
+
+// Endpoints which may apply a write twice if they're retried.
+var nonIdempotentEndpoints = map[string]bool{
+	"/batch": true,
+}
+
+// Implements a [RetryPolicy] on top of the retrying HTTP client.
+type retrier struct {
+	policy RetryPolicy
+	budget *retryBudget
+}
+
+func newRetrier(policy RetryPolicy) *retrier {
+	r := &retrier{policy: policy}
+	if policy.Budget != nil {
+		r.budget = &retryBudget{
+			ratio:  policy.Budget.Ratio,
+			burst:  math.Max(float64(policy.Budget.Burst), 1),
+			tokens: math.Max(float64(policy.Budget.Burst), 1),
+		}
+	}
+	return r
+}
+
+// The most retries any endpoint is allowed, which bounds the retrying HTTP
+// client. Limits for individual endpoints are enforced by checkRetry.
+func (r *retrier) maxRetries() int {
+	max := r.policy.MaxRetries
+	for _, n := range r.policy.EndpointMaxRetries {
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+func (r *retrier) endpointMaxRetries(endpoint string) int {
+	if n, ok := r.policy.EndpointMaxRetries[endpoint]; ok {
+		return n
+	}
+	return r.policy.MaxRetries
+}
+
+// Called once per call, to fund the retry budget.
+func (r *retrier) startCall() {
+	if r.budget != nil {
+		r.budget.deposit()
+	}
+}
+
+// Decides whether to retry after an attempt. Used as the CheckRetry function
+// of the retrying HTTP client.
+func (r *retrier) checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	retry, checkErr := retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+	if !retry {
+		return false, checkErr
+	}
+	// Retrying sooner than Retry-After asks would be pointless, so if the
+	// wait is too long, give up and return the response as it is.
+	if wait, ok := retryAfter(resp); ok && !r.canWait(ctx, wait) {
+		return false, nil
+	}
+	if info := callInfoFromContext(ctx); info != nil {
+		if info.retries >= r.endpointMaxRetries(info.endpoint) {
+			return false, nil
+		}
+		if nonIdempotentEndpoints[info.endpoint] && idempotencyKeyFromContext(ctx) == "" {
+			return false, nil
+		}
+	}
+	if r.budget != nil && !r.budget.withdraw() {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Reports whether the policy allows waiting for the given duration before
+// retrying: no longer than WaitMax, and not past the context's deadline.
+func (r *retrier) canWait(ctx context.Context, wait time.Duration) bool {
+	if wait > r.policy.WaitMax {
+		return false
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return false
+	}
+	return true
+}
+
+// Computes the wait before the next attempt. Used as the Backoff function of
+// the retrying HTTP client. A Retry-After header is honoured; checkRetry has
+// already given up if it asks for longer than the policy allows.
+func (r *retrier) backoff(min, max time.Duration, attempt int, resp *http.Response) time.Duration {
+	if wait, ok := retryAfter(resp); ok {
+		return wait
+	}
+	wait := float64(min) * math.Pow(2, float64(attempt))
+	if wait > float64(max) || math.IsInf(wait, 0) {
+		wait = float64(max)
+	}
+	if r.policy.Jitter > 0 {
+		wait -= wait * r.policy.Jitter * rand.Float64()
+	}
+	return time.Duration(wait)
+}
+
+// Parses the Retry-After header of a 429 or 503 response, which is either a
+// number of seconds or a date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// A token bucket: every call deposits ratio tokens, and every retry withdraws
+// one. The bucket holds at most burst tokens, and starts full.
+type retryBudget struct {
+	mu     sync.Mutex
+	ratio  float64
+	burst  float64
+	tokens float64
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.tokens+b.ratio, b.burst)
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,139 @@
+package oso
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var calls int32
+	var idempotencyKey atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		idempotencyKey.Store(r.Header.Get("Idempotency-Key"))
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	newClient := func(policy RetryPolicy) OsoClientCtx {
+		policy.WaitMin = time.Millisecond
+		policy.WaitMax = time.Millisecond
+		o, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", WithRetryPolicy(policy))
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		return o
+	}
+	expectCalls := func(name string, expected int32) {
+		t.Helper()
+		if n := atomic.SwapInt32(&calls, 0); n != expected {
+			t.Fatalf("%s: server called %d times, want %d", name, n, expected)
+		}
+	}
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+	fact := NewFact("has_role", alice, String("member"), acme)
+
+	o := newClient(RetryPolicy{MaxRetries: 2, EndpointMaxRetries: map[string]int{"/actions": 0}})
+	o.Authorize(alice, "read", acme)
+	expectCalls("Authorize", 3)
+	o.Actions(alice, acme)
+	expectCalls("Actions", 1)
+
+	// Writes are only retried with an idempotency key.
+	o.Insert(fact)
+	expectCalls("Insert", 1)
+	o.InsertCtx(WithIdempotencyKey(context.Background(), "insert-1"), fact)
+	expectCalls("Insert with idempotency key", 3)
+	if key := idempotencyKey.Load(); key != "insert-1" {
+		t.Fatalf("Idempotency-Key = %v, want %q", key, "insert-1")
+	}
+
+	// The budget starts with Burst retries, and each call adds Ratio more.
+	o = newClient(RetryPolicy{MaxRetries: 5, Budget: &RetryBudget{Ratio: 0.5, Burst: 2}})
+	o.Authorize(alice, "read", acme)
+	expectCalls("Authorize with full budget", 3)
+	o.Authorize(alice, "read", acme)
+	expectCalls("Authorize with exhausted budget", 1)
+	o.Authorize(alice, "read", acme)
+	expectCalls("Authorize with replenished budget", 2)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	r := newRetrier(RetryPolicy{Jitter: 0.5})
+	for attempt, expected := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond} {
+		for i := 0; i < 20; i++ {
+			wait := r.backoff(10*time.Millisecond, 50*time.Millisecond, attempt, nil)
+			if wait > expected || wait < expected/2 {
+				t.Fatalf("backoff for attempt %d = %v, want between %v and %v", attempt, wait, expected/2, expected)
+			}
+		}
+	}
+
+	res := &http.Response{StatusCode: 429, Header: http.Header{}}
+	res.Header.Set("Retry-After", "7")
+	if wait := r.backoff(10*time.Millisecond, 10*time.Second, 0, res); wait != 7*time.Second {
+		t.Fatalf("backoff with Retry-After = %v, want %v", wait, 7*time.Second)
+	}
+	res.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if wait := r.backoff(10*time.Millisecond, 2*time.Hour, 0, res); wait < 59*time.Minute || wait > time.Hour {
+		t.Fatalf("backoff with Retry-After date = %v, want about an hour", wait)
+	}
+	res.Header.Set("Retry-After", "soon")
+	if wait := r.backoff(10*time.Millisecond, 50*time.Millisecond, 0, res); wait > 10*time.Millisecond {
+		t.Fatalf("backoff with invalid Retry-After = %v", wait)
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	for _, jitter := range []float64{-0.1, 1.5} {
+		if _, err := New("http://localhost", "e_0123456789_12345_osotesttoken01xiIn", WithRetryPolicy(RetryPolicy{Jitter: jitter})); err == nil {
+			t.Errorf("New with Jitter %v succeeded, want error", jitter)
+		}
+	}
+	if _, err := New("http://localhost", "e_0123456789_12345_osotesttoken01xiIn", WithRetryPolicy(RetryPolicy{Jitter: 1})); err != nil {
+		t.Errorf("New with Jitter 1 failed: %v", err)
+	}
+}
+
+func TestRetryAfterBeyondWaitMax(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(429)
+		w.Write([]byte(`{"message":"slow down"}`))
+	}))
+	defer server.Close()
+
+	alice := NewValue("User", "alice")
+	acme := NewValue("Repo", "acme")
+	deadline, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, tc := range []struct {
+		name    string
+		ctx     context.Context
+		waitMax time.Duration
+	}{
+		{"Retry-After beyond WaitMax", context.Background(), 50 * time.Millisecond},
+		{"Retry-After beyond deadline", deadline, time.Hour},
+	} {
+		o, err := New(server.URL, "e_0123456789_12345_osotesttoken01xiIn", WithRetryPolicy(RetryPolicy{MaxRetries: 3, WaitMin: time.Millisecond, WaitMax: tc.waitMax}))
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		_, err = o.AuthorizeCtx(tc.ctx, alice, "read", acme, nil)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("%s: Authorize error = %v, want an APIError matching ErrRateLimited", tc.name, err)
+		}
+		if n := atomic.SwapInt32(&calls, 0); n != 1 {
+			t.Fatalf("%s: server called %d times, want 1", tc.name, n)
+		}
+	}
+}
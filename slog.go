@@ -0,0 +1,51 @@
+//go:build go1.21
+
+package oso
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Log the client's events to the given structured logger:
+//
+//   - request started and finished (debug), or failed (warn)
+//   - request retried (info)
+//   - request sent to a fallback node (warn)
+//   - request payload too large (warn)
+//   - parity expectation failed to send (error)
+//   - degraded decision made by an [ErrorPolicy] (warn)
+//
+// The API key is redacted from every event. Replaces any logger given with
+// [WithLogger].
+func WithSlogLogger(logger *slog.Logger) Option {
+	return func(o *clientOptions) {
+		if logger == nil {
+			o.logger = nil
+			return
+		}
+		o.logger = slogLogger{logger}
+	}
+}
+
+// Adapts a [slog.Logger] to the leveled logger interface of the retrying HTTP
+// client.
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelDebug, msg, keysAndValues...)
+}
+
+func (l slogLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelInfo, msg, keysAndValues...)
+}
+
+func (l slogLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelWarn, msg, keysAndValues...)
+}
+
+func (l slogLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelError, msg, keysAndValues...)
+}
@@ -0,0 +1,103 @@
+//go:build go1.21
+
+package oso
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSlogLogger(t *testing.T) {
+	const apiKey = "e_0123456789_12345_osotesttoken01xiIn"
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/authorize":
+			w.WriteHeader(503)
+		default:
+			// Echo the credentials, to check that they're redacted.
+			w.WriteHeader(404)
+			w.Write([]byte(`{"message":"not found for ` + r.Header.Get("Authorization") + `"}`))
+		}
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	defer fallback.Close()
+
+	buf := &syncBuffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	o, err := New(primary.URL, apiKey,
+		WithFallbackUrl(fallback.URL),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond}),
+		WithSlogLogger(logger))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if allowed, err := o.Authorize(NewValue("User", "alice"), "read", NewValue("Repo", "acme")); err != nil || !allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+	}
+	if _, err := o.Get(NewFactPattern("has_role", nil, nil, nil)); err == nil {
+		t.Fatalf("Get succeeded, want error")
+	}
+	if err := o.Insert(NewFact("big", String(strings.Repeat("x", maxBodySize)))); err == nil {
+		t.Fatalf("Insert succeeded, want error")
+	}
+
+	output := buf.String()
+	if strings.Contains(output, apiKey) {
+		t.Fatalf("log contains the API key:\n%s", output)
+	}
+	events := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var event struct {
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		events[event.Msg] = event.Level
+	}
+	expected := map[string]string{
+		"oso: request started":                  "DEBUG",
+		"oso: request finished":                 "DEBUG",
+		"oso: retrying request":                 "INFO",
+		"oso: sending request to fallback node": "WARN",
+		"oso: request failed":                   "WARN",
+		"oso: request payload too large":        "WARN",
+	}
+	for msg, level := range expected {
+		if events[msg] != level {
+			t.Errorf("event %q logged at %q, want %q", msg, events[msg], level)
+		}
+	}
+	if !strings.Contains(output, "Bearer "+redacted) {
+		t.Errorf("log doesn't contain the redacted error:\n%s", output)
+	}
+}
@@ -0,0 +1,132 @@
+package oso
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// A Tracer traces the calls a client makes to Oso Cloud. Implementations must
+// be safe for concurrent use. The osotrace package provides an OpenTelemetry
+// implementation.
+type Tracer interface {
+	// Called when an API call starts. The returned context, which usually
+	// carries a span, is used for the call's HTTP requests and passed to
+	// EndCall.
+	StartCall(ctx context.Context, call TracedCall) context.Context
+	// Called before every HTTP request of a call, including retries and
+	// requests to a fallback node, to add trace context headers to it.
+	Inject(ctx context.Context, header http.Header)
+	// Called once for every call, when it has finished.
+	EndCall(ctx context.Context, result TracedResult)
+}
+
+// An API call, as it starts.
+type TracedCall struct {
+	// The API endpoint, eg. "/authorize".
+	Endpoint string
+	// The HTTP method, eg. "POST".
+	Method string
+	// The types of the actor and resource of an authorization request, if
+	// [WithTracedTypes] is given. AuthorizeResources has no single resource
+	// type. IDs are never reported.
+	ActorType    string
+	ResourceType string
+}
+
+// The outcome of an API call. A call may be made of several HTTP requests:
+// retries, and a final request to the fallback URL.
+type TracedResult struct {
+	// The status code of the final response, or 0 if there was none.
+	StatusCode int
+	// The Oso request ID of the final response, if there was one.
+	RequestID string
+	// The number of retries after the initial request.
+	Retries int
+	// Whether the call was sent to the fallback URL.
+	Fallback bool
+	// The error the call failed with, if any.
+	Err error
+}
+
+// Trace every call to Oso Cloud with the given tracer.
+func WithTracer(tracer Tracer) Option {
+	return func(o *clientOptions) {
+		o.tracer = tracer
+	}
+}
+
+// Also report the actor and resource types of authorization requests to the
+// tracer. Types are omitted by default, as they may reveal how an application
+// models its data. IDs are never reported.
+func WithTracedTypes() Option {
+	return func(o *clientOptions) {
+		o.tracedTypes = true
+	}
+}
+
+type tracer struct {
+	tracer Tracer
+	types  bool
+}
+
+func newTracer(o clientOptions) *tracer {
+	if o.tracer == nil {
+		return nil
+	}
+	return &tracer{tracer: o.tracer, types: o.tracedTypes}
+}
+
+// Reports the start of the call, if tracing is enabled. The returned context
+// carries the call's span, if any.
+func (t *tracer) startCall(ctx context.Context, requestData RequestData) context.Context {
+	if t == nil {
+		return ctx
+	}
+	call := TracedCall{Endpoint: requestData.path, Method: requestData.method}
+	if t.types {
+		call.ActorType, call.ResourceType = decisionTypes(requestData.data)
+	}
+	return t.tracer.StartCall(ctx, call)
+}
+
+// Reports the outcome of the call started by startCall.
+func (t *tracer) finishCall(ctx context.Context, res *http.Response, err error) {
+	if t == nil {
+		return
+	}
+	info := callInfoFromContext(ctx)
+	result := TracedResult{Retries: info.retries, Fallback: info.fallback, Err: err}
+	var apiErr *APIError
+	if res != nil {
+		result.StatusCode = res.StatusCode
+		result.RequestID = res.Header.Get("X-Request-ID")
+	} else if errors.As(err, &apiErr) {
+		result.StatusCode = apiErr.StatusCode
+		result.RequestID = apiErr.RequestID
+	}
+	t.tracer.EndCall(ctx, result)
+}
+
+// Adds trace context headers for the current call to the request.
+func (t *tracer) inject(ctx context.Context, req *http.Request) {
+	if t == nil {
+		return
+	}
+	t.tracer.Inject(ctx, req.Header)
+}
+
+// Returns the actor and resource types of an authorization request.
+func decisionTypes(data interface{}) (string, string) {
+	switch q := data.(type) {
+	case authorizeQuery:
+		return q.ActorType, q.ResourceType
+	case authorizeResourcesQuery:
+		return q.ActorType, ""
+	case listQuery:
+		return q.ActorType, q.ResourceType
+	case actionsQuery:
+		return q.ActorType, q.ResourceType
+	}
+	return "", ""
+}
@@ -0,0 +1,111 @@
+package oso
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeSpanKey struct{}
+
+// A tracer which starts numbered spans, sends their number in a header, and
+// records the calls as they end.
+type fakeTracer struct {
+	mu      sync.Mutex
+	started []TracedCall
+	ended   []TracedResult
+}
+
+func (t *fakeTracer) StartCall(ctx context.Context, call TracedCall) context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.started = append(t.started, call)
+	return context.WithValue(ctx, fakeSpanKey{}, len(t.started))
+}
+
+func (t *fakeTracer) Inject(ctx context.Context, header http.Header) {
+	if span, ok := ctx.Value(fakeSpanKey{}).(int); ok {
+		header.Set("X-Fake-Span", strconv.Itoa(span))
+	}
+}
+
+func (t *fakeTracer) EndCall(ctx context.Context, result TracedResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ctx.Value(fakeSpanKey{}) != len(t.started) {
+		panic("EndCall called without the context returned by StartCall")
+	}
+	t.ended = append(t.ended, result)
+}
+
+func (t *fakeTracer) calls() ([]TracedCall, []TracedResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	started, ended := t.started, t.ended
+	t.started, t.ended = nil, nil
+	return started, ended
+}
+
+func TestTracing(t *testing.T) {
+	var primaryCalls int32
+	var spanHeader atomic.Value
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spanHeader.Store(r.Header.Get("X-Fake-Span"))
+		w.Header().Set("X-Request-ID", "primary-request")
+		if r.URL.Path == "/api/batch" || (r.URL.Path == "/api/authorize" && atomic.AddInt32(&primaryCalls, 1) <= 2) {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "fallback-request")
+		w.Write([]byte(`{"allowed":true}`))
+	}))
+	defer fallback.Close()
+
+	tracer := &fakeTracer{}
+	o, err := New(primary.URL, "e_0123456789_12345_osotesttoken01xiIn",
+		WithFallbackUrl(fallback.URL),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond}),
+		WithTracer(tracer),
+		WithTracedTypes())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	allowed, err := o.Authorize(NewValue("User", "alice"), "read", NewValue("Repo", "acme"))
+	if err != nil || !allowed {
+		t.Fatalf("Authorize = %t, %v, want %t", allowed, err, true)
+	}
+	started, ended := tracer.calls()
+	expectedCall := TracedCall{Endpoint: "/authorize", Method: "POST", ActorType: "User", ResourceType: "Repo"}
+	if !reflect.DeepEqual(started, []TracedCall{expectedCall}) {
+		t.Fatalf("started calls = %+v, want %+v", started, expectedCall)
+	}
+	expectedResult := TracedResult{StatusCode: 200, RequestID: "fallback-request", Retries: 1, Fallback: true}
+	if !reflect.DeepEqual(ended, []TracedResult{expectedResult}) {
+		t.Fatalf("ended calls = %+v, want %+v", ended, expectedResult)
+	}
+	if header, _ := spanHeader.Load().(string); header != "1" {
+		t.Fatalf("X-Fake-Span = %q, want %q", header, "1")
+	}
+
+	if err := o.Insert(NewFact("is_public", NewValue("Repo", "acme"))); err == nil {
+		t.Fatalf("Insert succeeded, want error")
+	}
+	started, ended = tracer.calls()
+	if len(started) != 1 || started[0].ActorType != "" || started[0].ResourceType != "" {
+		t.Fatalf("started calls = %+v, want one without types", started)
+	}
+	if len(ended) != 1 || ended[0].Err == nil || ended[0].StatusCode != 503 || ended[0].RequestID != "primary-request" {
+		t.Fatalf("ended calls = %+v, want one failed with a 503", ended)
+	}
+}